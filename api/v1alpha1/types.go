@@ -4,12 +4,18 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+//+kubebuilder:printcolumn:name="API",type="string",JSONPath=".status.conditions[?(@.type==\"APIReachable\")].status"
+//+kubebuilder:printcolumn:name="Last Upload",type="date",JSONPath=".status.lastUploadTime"
+//+kubebuilder:printcolumn:name="Reason",type="string",priority=1,JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ConnectorConfig is the Schema for the connectorconfigs API
@@ -40,7 +46,7 @@ type ConnectorConfigSpec struct {
 	// ClusterContext contains information about the cluster
 	ClusterContext ClusterContextConfig `json:"clusterContext"`
 
-	// Collectors is a list of collectors to enable
+	// Collectors is a list of collectors to enable. All collectors run when unset.
 	// +optional
 	Collectors []CollectorSpec `json:"collectors,omitempty"`
 
@@ -55,6 +61,21 @@ type ConnectorConfigSpec struct {
 	// MetricsServer defines the configuration for Kubernetes Metrics Server
 	// +optional
 	MetricsServer *MetricsServerConfig `json:"metricsServer,omitempty"`
+
+	// Kubelet defines the configuration for reading the kubelet stats
+	// summary of each node
+	// +optional
+	Kubelet *KubeletConfig `json:"kubelet,omitempty"`
+
+	// Sinks lists the destinations collected metrics are written to.
+	// Metrics are sent to the HakonGo API only when unset.
+	// +optional
+	Sinks []SinkSpec `json:"sinks,omitempty"`
+
+	// CustomMetrics lists PromQL queries whose results are attached to the
+	// resources their series belong to. Requires Prometheus.
+	// +optional
+	CustomMetrics []CustomMetricSpec `json:"customMetrics,omitempty"`
 }
 
 //+k8s:deepcopy-gen=true
@@ -90,7 +111,8 @@ type ClusterContextConfig struct {
 
 // CollectorSpec defines configuration for a specific collector
 type CollectorSpec struct {
-	// Name of the collector
+	// Name of the collector: pod, node, pv, service, namespace, workload,
+	// ingress, event, custom or kubelet
 	Name string `json:"name"`
 
 	// Collection interval in seconds
@@ -105,6 +127,43 @@ type CollectorSpec struct {
 
 //+k8s:deepcopy-gen=true
 
+// CustomMetricSpec defines a PromQL query collected as a custom metric
+type CustomMetricSpec struct {
+	// Name is the key of the metric in the custom metrics of each resource
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Query is the PromQL instant query to run
+	// +kubebuilder:validation:Required
+	Query string `json:"query"`
+
+	// Resource is the kind the results are attached to. When unset it is
+	// inferred from the pod, workload, node and namespace labels of each series.
+	// Workload results are reported under the kind of the workload_kind
+	// label, Deployment when it is missing.
+	// +optional
+	// +kubebuilder:validation:Enum=Pod;Namespace;Node;Workload
+	Resource string `json:"resource,omitempty"`
+
+	// Labels maps the resource labels namespace, pod, node, workload and
+	// workload_kind to the series labels carrying them
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Aggregator combines series that map to the same resource
+	// +optional
+	// +kubebuilder:validation:Enum=sum;avg;min;max;count
+	// +kubebuilder:default=sum
+	Aggregator string `json:"aggregator,omitempty"`
+
+	// Interval is the minimum time between two runs of the query, such as
+	// 5m. The query runs on every collection when unset.
+	// +optional
+	Interval string `json:"interval,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
 // HakonGoConfig defines configuration for the HakonGo API
 type HakonGoConfig struct {
 	// BaseURL is the base URL for the HakonGo API
@@ -114,6 +173,112 @@ type HakonGoConfig struct {
 	// APIKey defines the API key for authentication
 	// +kubebuilder:validation:Required
 	APIKey corev1.SecretKeySelector `json:"apiKey"`
+
+	// Compression selects the algorithm used to compress request bodies.
+	// Requests are sent uncompressed when unset or set to none.
+	// +optional
+	// +kubebuilder:validation:Enum=none;gzip;zstd
+	Compression string `json:"compression,omitempty"`
+
+	// Spool defines an on-disk buffer for batches that could not be delivered
+	// +optional
+	Spool *SpoolConfig `json:"spool,omitempty"`
+
+	// MaxBatchSize limits the uncompressed size of a single request body.
+	// Larger payloads are split into several requests.
+	// +optional
+	// +kubebuilder:default="4Mi"
+	MaxBatchSize *resource.Quantity `json:"maxBatchSize,omitempty"`
+
+	// MaxBatchItems limits the number of resources sent in a single request
+	// +optional
+	// +kubebuilder:default=1000
+	// +kubebuilder:validation:Minimum=1
+	MaxBatchItems int32 `json:"maxBatchItems,omitempty"`
+
+	// ConfigPollInterval is how often the cluster configuration is fetched
+	// from the API. Remote settings override the spec. Set to 0 to disable.
+	// +optional
+	// +kubebuilder:default="5m"
+	ConfigPollInterval string `json:"configPollInterval,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// SpoolConfig defines the on-disk buffer for undelivered batches
+type SpoolConfig struct {
+	// Path is the directory batches are written to, typically an emptyDir or PVC mount
+	// +kubebuilder:validation:Required
+	Path string `json:"path"`
+
+	// MaxSize is the maximum total size of spooled batches. The oldest
+	// batches are evicted first when it is exceeded.
+	// +optional
+	// +kubebuilder:default="512Mi"
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// MaxAge is how long a batch is kept before it is evicted
+	// +optional
+	// +kubebuilder:default="24h"
+	MaxAge string `json:"maxAge,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// SinkSpec defines a destination for collected metrics
+type SinkSpec struct {
+	// Name identifies the sink in logs
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Type of the sink
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=hakongo;file;stdout;webhook
+	Type string `json:"type"`
+
+	// Kinds restricts the sink to resources of these kinds (e.g. Pod, Node).
+	// All resources are written when unset.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// File configures a sink of type file
+	// +optional
+	File *FileSinkConfig `json:"file,omitempty"`
+
+	// Webhook configures a sink of type webhook
+	// +optional
+	Webhook *WebhookSinkConfig `json:"webhook,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// FileSinkConfig defines a sink that appends NDJSON records to a local file
+type FileSinkConfig struct {
+	// Path of the file records are appended to
+	// +kubebuilder:validation:Required
+	Path string `json:"path"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// WebhookSinkConfig defines a sink that posts each collection cycle to an HTTP endpoint
+type WebhookSinkConfig struct {
+	// URL the metrics are posted to
+	// +kubebuilder:validation:Required
+	URL string `json:"url"`
+
+	// Headers are added to every request
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// BearerToken is sent in the Authorization header
+	// +optional
+	BearerToken *corev1.SecretKeySelector `json:"bearerToken,omitempty"`
+
+	// Timeout for each request
+	// +optional
+	// +kubebuilder:default="30s"
+	Timeout string `json:"timeout,omitempty"`
 }
 
 //+k8s:deepcopy-gen=true
@@ -129,6 +294,12 @@ type CostConfig struct {
 	// +optional
 	PriceBook string `json:"priceBook,omitempty"`
 
+	// NetworkPerGB is the price of one GiB of transmitted traffic as a
+	// decimal, for example "0.09". Network costs are zero when unset.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	NetworkPerGB string `json:"networkPerGB,omitempty"`
+
 	// Labels to be added to cost metrics
 	// +optional
 	Labels map[string]string `json:"labels,omitempty"`
@@ -161,6 +332,12 @@ type PrometheusConfig struct {
 	// +kubebuilder:default="30s"
 	QueryTimeout string `json:"queryTimeout,omitempty"`
 
+	// MaxConcurrentQueries limits the Prometheus queries in flight at once
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentQueries int32 `json:"maxConcurrentQueries,omitempty"`
+
 	// BasicAuth defines basic authentication configuration
 	// +optional
 	BasicAuth *BasicAuthConfig `json:"basicAuth,omitempty"`
@@ -172,6 +349,48 @@ type PrometheusConfig struct {
 	// TLSConfig defines TLS configuration
 	// +optional
 	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
+
+	// Queries customizes the PromQL queries run by the collectors
+	// +optional
+	Queries *PrometheusQueries `json:"queries,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// PrometheusQueries customizes the PromQL queries run by the collectors
+type PrometheusQueries struct {
+	// Preset selects the queries matching a Prometheus distribution
+	// +optional
+	// +kubebuilder:default=default
+	// +kubebuilder:validation:Enum=default;kube-prometheus-stack;gke-managed;victoriametrics
+	Preset string `json:"preset,omitempty"`
+
+	// Templates override queries of the preset by name: containerCPU,
+	// containerMemory, containerCPUThrottling, containerMemoryRSS,
+	// containerPageFaults, podNetwork, nodeCPU, nodeMemory, nodeNetwork or
+	// volumeStats. Templates are Go templates
+	// that can use {{.Window}}, the range of rate(), {{.Interval}}, the
+	// collection interval counters report their increase over, and
+	// {{.Selector}}, the selector below prefixed with a comma.
+	// +optional
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// LabelRenames maps labels of the query results to the labels the
+	// connector reads: namespace, pod, container, node, failure_type,
+	// traffic, persistentvolumeclaim and stat. Each label can be the target
+	// of one rename only.
+	// +optional
+	LabelRenames map[string]string `json:"labelRenames,omitempty"`
+
+	// Window is the range of rate() in the queries
+	// +optional
+	// +kubebuilder:default="5m"
+	Window string `json:"window,omitempty"`
+
+	// Selector holds label matchers added to every query, for example
+	// cluster="prod" when several clusters share a Prometheus
+	// +optional
+	Selector string `json:"selector,omitempty"`
 }
 
 //+k8s:deepcopy-gen:interfaces=github.com/openshift/hive/pkg/apis/hive/v1alpha1.SecretKeySelector
@@ -223,11 +442,77 @@ type MetricsServerConfig struct {
 
 //+k8s:deepcopy-gen=true
 
+// KubeletConfig defines configuration for the kubelet stats summary, read
+// through the API server node proxy
+// +kubebuilder:object:generate=true
+type KubeletConfig struct {
+	// Enabled defines whether to collect pod and container stats from the
+	// kubelet of each node
+	// +optional
+	// +kubebuilder:default=false
+	Enabled bool `json:"enabled"`
+
+	// MaxConcurrentNodes limits the number of nodes queried in parallel
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentNodes int32 `json:"maxConcurrentNodes,omitempty"`
+}
+
+// Condition types reported in ConnectorConfigStatus.Conditions
+const (
+	// ConditionReady is true while metrics are collected and delivered
+	ConditionReady = "Ready"
+
+	// ConditionAPIReachable is true when the HakonGo API answered the last request
+	ConditionAPIReachable = "APIReachable"
+
+	// ConditionPrometheusReachable is true when Prometheus answers queries.
+	// It is only reported when Prometheus is configured.
+	ConditionPrometheusReachable = "PrometheusReachable"
+
+	// ConditionMetricsServerAvailable is true when the Metrics Server API serves
+	// metrics. It is only reported when the Metrics Server is used.
+	ConditionMetricsServerAvailable = "MetricsServerAvailable"
+
+	// ConditionKubeletReachable is true when the kubelet stats summary can be
+	// read through the node proxy. It is only reported when spec.kubelet is
+	// enabled.
+	ConditionKubeletReachable = "KubeletReachable"
+
+	// ConditionDegraded is true when collectors fail or a metrics source is down
+	ConditionDegraded = "Degraded"
+
+	// ConditionCollectorsValid is false when spec.collectors names an unknown collector
+	ConditionCollectorsValid = "CollectorsValid"
+)
+
+//+k8s:deepcopy-gen=true
+
 // ConnectorConfigStatus defines the observed state of ConnectorConfig
 type ConnectorConfigStatus struct {
 	// LastCollectionTime is the last time metrics were collected
 	LastCollectionTime *metav1.Time `json:"lastCollectionTime,omitempty"`
 
+	// LastUploadTime is the last time metrics were written to the sinks
+	// +optional
+	LastUploadTime *metav1.Time `json:"lastUploadTime,omitempty"`
+
+	// Collectors reports the schedule of each collector
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Collectors []CollectorStatus `json:"collectors,omitempty"`
+
+	// EffectiveConfig is the collection configuration in use after merging
+	// the spec with the remote cluster configuration
+	// +optional
+	EffectiveConfig *EffectiveConfig `json:"effectiveConfig,omitempty"`
+
+	// PrometheusQueries accounts for the queries sent to Prometheus
+	// +optional
+	PrometheusQueries *PrometheusQueryStatus `json:"prometheusQueries,omitempty"`
+
 	// Conditions represent the latest available observations
 	// +optional
 	// +patchMergeKey=type
@@ -235,6 +520,125 @@ type ConnectorConfigStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+//+k8s:deepcopy-gen=true
+
+// PrometheusQueryStatus summarizes the queries sent to Prometheus since the
+// pipeline's Prometheus client was created
+type PrometheusQueryStatus struct {
+	// Queries is the number of completed queries
+	Queries int64 `json:"queries"`
+
+	// Errors is the number of failed queries, including timeouts
+	Errors int64 `json:"errors"`
+
+	// Timeouts is the number of queries that exceeded the query timeout
+	Timeouts int64 `json:"timeouts"`
+
+	// AverageLatency is the mean query latency
+	// +optional
+	AverageLatency string `json:"averageLatency,omitempty"`
+
+	// MaxLatency is the slowest query latency
+	// +optional
+	MaxLatency string `json:"maxLatency,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// CollectorStatus describes the schedule and health of a collector
+type CollectorStatus struct {
+	// Name of the collector
+	Name string `json:"name"`
+
+	// Interval between two runs of the collector
+	Interval string `json:"interval"`
+
+	// LastRunTime is when the last completed run started
+	// +optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+
+	// LastRunDuration is how long the last completed run took
+	// +optional
+	LastRunDuration string `json:"lastRunDuration,omitempty"`
+
+	// NextRunTime is when the next run is due
+	// +optional
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+
+	// LastSuccessTime is when the collector last succeeded
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+
+	// ItemCount is the number of resources returned by the last successful run
+	// +optional
+	ItemCount int32 `json:"itemCount,omitempty"`
+
+	// Degraded describes the data the last successful run left out, such as
+	// the pods of nodes whose kubelet did not answer
+	// +optional
+	Degraded string `json:"degraded,omitempty"`
+
+	// LastError is the error of the last run, if it failed
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// LastErrorClass groups the last collection error by cause, e.g. Forbidden or Timeout
+	// +optional
+	LastErrorClass string `json:"lastErrorClass,omitempty"`
+
+	// ConsecutiveFailures counts failed collections since the last success
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// CircuitOpenUntil is set while the collector is backed off after
+	// repeated failures. Runs are skipped until then.
+	// +optional
+	CircuitOpenUntil *metav1.Time `json:"circuitOpenUntil,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// EffectiveConfig describes the collection configuration in use
+type EffectiveConfig struct {
+	// Source is "spec" when only the spec applies, or "remote" when the
+	// remote cluster configuration was merged in
+	Source string `json:"source"`
+
+	// RemoteConfigTime is when the remote cluster configuration was last fetched
+	// +optional
+	RemoteConfigTime *metav1.Time `json:"remoteConfigTime,omitempty"`
+
+	// CollectionInterval is how often metrics are collected
+	CollectionInterval string `json:"collectionInterval"`
+
+	// IncludeNamespaces limits collection to these namespaces
+	// +optional
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+
+	// ExcludeNamespaces are skipped during collection
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// ResourceTypes limits collection to these resource types
+	// +optional
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+
+	// Cost contains the rates used for cost estimates
+	// +optional
+	Cost *EffectiveCostRates `json:"cost,omitempty"`
+}
+
+//+k8s:deepcopy-gen=true
+
+// EffectiveCostRates are the hourly prices used for cost estimates, formatted as decimals
+type EffectiveCostRates struct {
+	Currency         string `json:"currency"`
+	CPUPerCoreHour   string `json:"cpuPerCoreHour"`
+	MemoryPerGBHour  string `json:"memoryPerGBHour"`
+	StoragePerGBHour string `json:"storagePerGBHour"`
+	NetworkPerGB     string `json:"networkPerGB"`
+}
+
 func init() {
 	SchemeBuilder.Register(&ConnectorConfig{}, &ConnectorConfigList{})
 }
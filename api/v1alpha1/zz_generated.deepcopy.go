@@ -102,6 +102,37 @@ func (in *CollectorSpec) DeepCopy() *CollectorSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CollectorStatus) DeepCopyInto(out *CollectorStatus) {
+	*out = *in
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRunTime != nil {
+		in, out := &in.NextRunTime, &out.NextRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessTime != nil {
+		in, out := &in.LastSuccessTime, &out.LastSuccessTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CircuitOpenUntil != nil {
+		in, out := &in.CircuitOpenUntil, &out.CircuitOpenUntil
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectorStatus.
+func (in *CollectorStatus) DeepCopy() *CollectorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CollectorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConnectorConfig) DeepCopyInto(out *ConnectorConfig) {
 	*out = *in
@@ -188,6 +219,25 @@ func (in *ConnectorConfigSpec) DeepCopyInto(out *ConnectorConfigSpec) {
 		*out = new(MetricsServerConfig)
 		**out = **in
 	}
+	if in.Kubelet != nil {
+		in, out := &in.Kubelet, &out.Kubelet
+		*out = new(KubeletConfig)
+		**out = **in
+	}
+	if in.Sinks != nil {
+		in, out := &in.Sinks, &out.Sinks
+		*out = make([]SinkSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CustomMetrics != nil {
+		in, out := &in.CustomMetrics, &out.CustomMetrics
+		*out = make([]CustomMetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectorConfigSpec.
@@ -207,6 +257,27 @@ func (in *ConnectorConfigStatus) DeepCopyInto(out *ConnectorConfigStatus) {
 		in, out := &in.LastCollectionTime, &out.LastCollectionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.LastUploadTime != nil {
+		in, out := &in.LastUploadTime, &out.LastUploadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Collectors != nil {
+		in, out := &in.Collectors, &out.Collectors
+		*out = make([]CollectorStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EffectiveConfig != nil {
+		in, out := &in.EffectiveConfig, &out.EffectiveConfig
+		*out = new(EffectiveConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrometheusQueries != nil {
+		in, out := &in.PrometheusQueries, &out.PrometheusQueries
+		*out = new(PrometheusQueryStatus)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -255,10 +326,111 @@ func (in *CostConfig) DeepCopy() *CostConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomMetricSpec) DeepCopyInto(out *CustomMetricSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomMetricSpec.
+func (in *CustomMetricSpec) DeepCopy() *CustomMetricSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomMetricSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveConfig) DeepCopyInto(out *EffectiveConfig) {
+	*out = *in
+	if in.RemoteConfigTime != nil {
+		in, out := &in.RemoteConfigTime, &out.RemoteConfigTime
+		*out = (*in).DeepCopy()
+	}
+	if in.IncludeNamespaces != nil {
+		in, out := &in.IncludeNamespaces, &out.IncludeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeNamespaces != nil {
+		in, out := &in.ExcludeNamespaces, &out.ExcludeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceTypes != nil {
+		in, out := &in.ResourceTypes, &out.ResourceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Cost != nil {
+		in, out := &in.Cost, &out.Cost
+		*out = new(EffectiveCostRates)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveConfig.
+func (in *EffectiveConfig) DeepCopy() *EffectiveConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveCostRates) DeepCopyInto(out *EffectiveCostRates) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveCostRates.
+func (in *EffectiveCostRates) DeepCopy() *EffectiveCostRates {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveCostRates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSinkConfig) DeepCopyInto(out *FileSinkConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSinkConfig.
+func (in *FileSinkConfig) DeepCopy() *FileSinkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSinkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HakonGoConfig) DeepCopyInto(out *HakonGoConfig) {
 	*out = *in
 	in.APIKey.DeepCopyInto(&out.APIKey)
+	if in.Spool != nil {
+		in, out := &in.Spool, &out.Spool
+		*out = new(SpoolConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxBatchSize != nil {
+		in, out := &in.MaxBatchSize, &out.MaxBatchSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HakonGoConfig.
@@ -271,6 +443,21 @@ func (in *HakonGoConfig) DeepCopy() *HakonGoConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeletConfig) DeepCopyInto(out *KubeletConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeletConfig.
+func (in *KubeletConfig) DeepCopy() *KubeletConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeletConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsServerConfig) DeepCopyInto(out *MetricsServerConfig) {
 	*out = *in
@@ -311,6 +498,11 @@ func (in *PrometheusConfig) DeepCopyInto(out *PrometheusConfig) {
 		*out = new(TLSConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Queries != nil {
+		in, out := &in.Queries, &out.Queries
+		*out = new(PrometheusQueries)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConfig.
@@ -323,6 +515,100 @@ func (in *PrometheusConfig) DeepCopy() *PrometheusConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusQueries) DeepCopyInto(out *PrometheusQueries) {
+	*out = *in
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LabelRenames != nil {
+		in, out := &in.LabelRenames, &out.LabelRenames
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusQueries.
+func (in *PrometheusQueries) DeepCopy() *PrometheusQueries {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusQueries)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusQueryStatus) DeepCopyInto(out *PrometheusQueryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusQueryStatus.
+func (in *PrometheusQueryStatus) DeepCopy() *PrometheusQueryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusQueryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SinkSpec) DeepCopyInto(out *SinkSpec) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(FileSinkConfig)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(WebhookSinkConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SinkSpec.
+func (in *SinkSpec) DeepCopy() *SinkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SinkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpoolConfig) DeepCopyInto(out *SpoolConfig) {
+	*out = *in
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpoolConfig.
+func (in *SpoolConfig) DeepCopy() *SpoolConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SpoolConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 	*out = *in
@@ -352,3 +638,30 @@ func (in *TLSConfig) DeepCopy() *TLSConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookSinkConfig) DeepCopyInto(out *WebhookSinkConfig) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BearerToken != nil {
+		in, out := &in.BearerToken, &out.BearerToken
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookSinkConfig.
+func (in *WebhookSinkConfig) DeepCopy() *WebhookSinkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookSinkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
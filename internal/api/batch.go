@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	// DefaultMaxBatchBytes is the default limit on the uncompressed size of a request body
+	DefaultMaxBatchBytes = 4 << 20
+
+	// DefaultMaxBatchItems is the default limit on the number of resources per request
+	DefaultMaxBatchItems = 1000
+
+	metricsPath      = "/v1/metrics"
+	eventMetricsPath = "/v1/metrics/events"
+)
+
+// Batch is a serialized request waiting to be delivered to the SaaS platform.
+// Batches are self-contained so they can be spooled to disk and replayed.
+type Batch struct {
+	// Path is the API endpoint the batch is posted to
+	Path string `json:"path"`
+
+	// Body is the uncompressed JSON payload
+	Body json.RawMessage `json:"body"`
+
+	// IdempotencyKey lets the API discard duplicate deliveries of the same
+	// chunk. It is sent in the Idempotency-Key header.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	// Index and Count locate the chunk within its collection cycle
+	Index int `json:"index"`
+	Count int `json:"count"`
+
+	// CreatedAt is when the batch was built
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BatchFailure describes a chunk that could not be delivered
+type BatchFailure struct {
+	Index          int
+	IdempotencyKey string
+	Err            error
+}
+
+// BatchError is returned when some chunks of a payload could not be
+// delivered. Chunks that are not listed were accepted by the API.
+type BatchError struct {
+	Total  int
+	Failed []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("chunk %d: %v", f.Index, f.Err))
+	}
+	return fmt.Sprintf("failed to deliver %d of %d chunk(s): %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// IdempotencyKey derives the deterministic key for a chunk. Retries and
+// spool replays of the same chunk always carry the same key.
+func IdempotencyKey(clusterID, cycleID, path string, index int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", clusterID, cycleID, path, index)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SendBatch posts a previously built batch to the SaaS platform
+func (c *Client) SendBatch(ctx context.Context, batch *Batch) error {
+	if err := c.post(ctx, batch); err != nil {
+		return fmt.Errorf("failed to send batch to %s: %w", batch.Path, err)
+	}
+	return nil
+}
+
+// SendBatches posts each batch in turn. It keeps going after a failure so
+// that one rejected chunk does not hold back the others, and reports the
+// failed chunks in a *BatchError.
+func (c *Client) SendBatches(ctx context.Context, batches []*Batch) error {
+	var failed []BatchFailure
+	for _, batch := range batches {
+		if err := c.SendBatch(ctx, batch); err != nil {
+			failed = append(failed, BatchFailure{
+				Index:          batch.Index,
+				IdempotencyKey: batch.IdempotencyKey,
+				Err:            err,
+			})
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BatchError{Total: len(batches), Failed: failed}
+}
+
+// IsPartialFailure reports whether err is a *BatchError where at least one
+// chunk was delivered
+func IsPartialFailure(err error) bool {
+	var batchErr *BatchError
+	return errors.As(err, &batchErr) && len(batchErr.Failed) < batchErr.Total
+}
+
+type span struct {
+	start, end int
+}
+
+// split groups count items into consecutive spans that respect the
+// client's item and byte limits. size returns the encoded size of item i.
+// An item larger than the byte limit gets a span of its own. Empty input
+// yields a single empty span so that an empty payload is still sent.
+func (c *Client) split(count int, size func(i int) int) []span {
+	var spans []span
+	start, bytes := 0, 0
+	for i := 0; i < count; i++ {
+		// Account for the separator between items
+		n := size(i) + 1
+		if i > start && (i-start >= c.maxBatchItems || bytes+n > c.maxBatchBytes) {
+			spans = append(spans, span{start, i})
+			start, bytes = i, 0
+		}
+		bytes += n
+	}
+	if start < count || len(spans) == 0 {
+		spans = append(spans, span{start, count})
+	}
+	return spans
+}
+
+func newBatch(path, clusterID, cycleID string, index, count int, body []byte, createdAt time.Time) *Batch {
+	return &Batch{
+		Path:           path,
+		Body:           body,
+		IdempotencyKey: IdempotencyKey(clusterID, cycleID, path, index),
+		Index:          index,
+		Count:          count,
+		CreatedAt:      createdAt,
+	}
+}
+
+// NewCycleID returns a unique identifier for a collection cycle
+func NewCycleID() string {
+	return string(uuid.NewUUID())
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMetrics(n int) []collector.ResourceMetrics {
+	metrics := make([]collector.ResourceMetrics, n)
+	for i := range metrics {
+		metrics[i] = collector.ResourceMetrics{
+			Name:      fmt.Sprintf("pod-%d", i),
+			Namespace: "default",
+			Kind:      "Pod",
+		}
+	}
+	return metrics
+}
+
+func TestClient_NewMetricsBatches_SplitsByItemCount(t *testing.T) {
+	client := NewClient(ClientConfig{MaxBatchItems: 3})
+
+	batches, err := client.NewMetricsBatches("test-cluster", "cycle-1", testMetrics(7))
+	assert.NoError(t, err)
+	assert.Len(t, batches, 3)
+
+	var total int
+	for i, batch := range batches {
+		var chunk []collector.ResourceMetrics
+		assert.NoError(t, json.Unmarshal(batch.Body, &chunk))
+		assert.LessOrEqual(t, len(chunk), 3)
+		assert.Equal(t, i, batch.Index)
+		assert.Equal(t, 3, batch.Count)
+		total += len(chunk)
+	}
+	assert.Equal(t, 7, total)
+}
+
+func TestClient_NewMetricsBatches_SplitsByBytes(t *testing.T) {
+	metrics := testMetrics(20)
+	item, err := json.Marshal(metrics[0])
+	assert.NoError(t, err)
+
+	client := NewClient(ClientConfig{MaxBatchBytes: 4 * (len(item) + 1)})
+
+	batches, err := client.NewMetricsBatches("test-cluster", "cycle-1", metrics)
+	assert.NoError(t, err)
+	assert.Greater(t, len(batches), 1)
+
+	var names []string
+	for _, batch := range batches {
+		// Allow for the enclosing brackets
+		assert.LessOrEqual(t, len(batch.Body), 4*(len(item)+1)+2)
+
+		var chunk []collector.ResourceMetrics
+		assert.NoError(t, json.Unmarshal(batch.Body, &chunk))
+		for _, m := range chunk {
+			names = append(names, m.Name)
+		}
+	}
+	assert.Len(t, names, 20)
+	assert.Equal(t, "pod-0", names[0])
+	assert.Equal(t, "pod-19", names[19])
+}
+
+func TestClient_NewMetricsBatches_EmptyPayload(t *testing.T) {
+	client := NewClient(ClientConfig{})
+
+	batches, err := client.NewMetricsBatches("test-cluster", "cycle-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, batches, 1)
+	assert.JSONEq(t, "[]", string(batches[0].Body))
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	key := IdempotencyKey("test-cluster", "cycle-1", metricsPath, 0)
+
+	assert.Equal(t, key, IdempotencyKey("test-cluster", "cycle-1", metricsPath, 0))
+	assert.NotEqual(t, key, IdempotencyKey("test-cluster", "cycle-1", metricsPath, 1))
+	assert.NotEqual(t, key, IdempotencyKey("test-cluster", "cycle-2", metricsPath, 0))
+	assert.NotEqual(t, key, IdempotencyKey("other-cluster", "cycle-1", metricsPath, 0))
+	assert.NotEqual(t, key, IdempotencyKey("test-cluster", "cycle-1", eventMetricsPath, 0))
+}
+
+func TestClient_SendBatches_ReportsPartialFailures(t *testing.T) {
+	var mu sync.Mutex
+	keys := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := r.Header.Get("Idempotency-Key")
+		keys[key]++
+
+		var chunk []collector.ResourceMetrics
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&chunk))
+		if chunk[0].Name == "pod-2" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:       server.URL,
+		Timeout:       5 * time.Second,
+		MaxBatchItems: 2,
+	})
+
+	batches, err := client.NewMetricsBatches("test-cluster", "cycle-1", testMetrics(6))
+	assert.NoError(t, err)
+	assert.Len(t, batches, 3)
+
+	err = client.SendBatches(context.Background(), batches)
+	assert.Error(t, err)
+	assert.True(t, IsPartialFailure(err))
+
+	var batchErr *BatchError
+	assert.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 3, batchErr.Total)
+	assert.Len(t, batchErr.Failed, 1)
+	assert.Equal(t, 1, batchErr.Failed[0].Index)
+	assert.Equal(t, batches[1].IdempotencyKey, batchErr.Failed[0].IdempotencyKey)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, apiErr.StatusCode)
+
+	// Every chunk was sent exactly once with its own key
+	assert.Len(t, keys, 3)
+	for _, batch := range batches {
+		assert.Equal(t, 1, keys[batch.IdempotencyKey])
+	}
+}
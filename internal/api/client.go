@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hakongo/kubernetes-connector/internal/collector"
@@ -13,9 +17,24 @@ import (
 
 // Client represents the SaaS API client
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL      string
+	apiKey       string
+	httpClient   *http.Client
+	maxRetries   int
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+	compression  Compression
+
+	maxBatchBytes int
+	maxBatchItems int
+
+	// compressionRejected is set once the server answers 415 to a
+	// compressed request, after which bodies are sent uncompressed
+	compressionRejected atomic.Bool
+
+	// healthMu guards health, the outcome of the most recent requests
+	healthMu sync.Mutex
+	health   Health
 }
 
 // ClientConfig contains configuration for the API client
@@ -26,50 +45,93 @@ type ClientConfig struct {
 	MaxRetries         int
 	RetryWaitDuration  time.Duration
 	CompressionEnabled bool
+
+	// Compression selects the algorithm used when CompressionEnabled is set.
+	// Defaults to gzip.
+	Compression Compression
+
+	// MaxBatchBytes limits the uncompressed size of a single request body.
+	// Defaults to DefaultMaxBatchBytes.
+	MaxBatchBytes int
+
+	// MaxBatchItems limits the number of resources in a single request.
+	// Defaults to DefaultMaxBatchItems.
+	MaxBatchItems int
 }
 
 // NewClient creates a new SaaS API client
 func NewClient(config ClientConfig) *Client {
-	return &Client{
+	c := &Client{
 		baseURL: config.BaseURL,
 		apiKey:  config.APIKey,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
+		maxRetries:   max(config.MaxRetries, 0),
+		retryWait:    config.RetryWaitDuration,
+		maxRetryWait: defaultMaxRetryWait,
+		compression:  compressionFor(config),
+
+		maxBatchBytes: config.MaxBatchBytes,
+		maxBatchItems: config.MaxBatchItems,
+	}
+	if c.maxBatchBytes <= 0 {
+		c.maxBatchBytes = DefaultMaxBatchBytes
+	}
+	if c.maxBatchItems <= 0 {
+		c.maxBatchItems = DefaultMaxBatchItems
 	}
+
+	return c
 }
 
-// SendMetrics sends collected metrics to the SaaS platform
-func (c *Client) SendMetrics(ctx context.Context, metrics []collector.ResourceMetrics) error {
-	payload, err := json.Marshal(metrics)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metrics: %w", err)
+// compressionFor resolves the compression algorithm from the client config
+func compressionFor(config ClientConfig) Compression {
+	if !config.CompressionEnabled || config.Compression == CompressionNone {
+		return ""
 	}
+	if config.Compression == "" {
+		return CompressionGzip
+	}
+	return config.Compression
+}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/v1/metrics", c.baseURL),
-		bytes.NewReader(payload),
-	)
+// SendMetrics sends collected metrics to the SaaS platform, split into
+// chunks that fit the configured batch limits
+func (c *Client) SendMetrics(ctx context.Context, metrics []collector.ResourceMetrics) error {
+	batches, err := c.NewMetricsBatches("", NewCycleID(), metrics)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
+	return c.SendBatches(ctx, batches)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send metrics: %w", err)
+// NewMetricsBatches serializes resource metrics for the /v1/metrics endpoint.
+// The metrics are split into chunks bounded by the client's byte and item
+// limits, and each chunk gets an idempotency key derived from the cluster,
+// the collection cycle and the chunk index.
+func (c *Client) NewMetricsBatches(clusterID, cycleID string, metrics []collector.ResourceMetrics) ([]*Batch, error) {
+	items := make([]json.RawMessage, len(metrics))
+	for i := range metrics {
+		item, err := json.Marshal(metrics[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		items[i] = item
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	spans := c.split(len(items), func(i int) int { return len(items[i]) })
+	now := time.Now().UTC()
+	batches := make([]*Batch, 0, len(spans))
+	for index, sp := range spans {
+		payload, err := json.Marshal(items[sp.start:sp.end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		batches = append(batches, newBatch(metricsPath, clusterID, cycleID, index, len(spans), payload, now))
 	}
 
-	return nil
+	return batches, nil
 }
 
 // EventMetricsPayload represents the payload for sending event metrics
@@ -126,30 +188,75 @@ type EventMetrics struct {
 
 // SendEventMetrics sends collected event metrics to the SaaS platform
 func (c *Client) SendEventMetrics(ctx context.Context, clusterID string, clusterContext map[string]interface{}, eventMetrics []collector.ResourceMetrics) error {
+	batches, err := c.NewEventMetricsBatches(clusterID, NewCycleID(), clusterContext, eventMetrics)
+	if err != nil {
+		return err
+	}
+	return c.SendBatches(ctx, batches)
+}
+
+// NewEventMetricsBatches serializes event metrics for the /v1/metrics/events
+// endpoint, split into chunks like NewMetricsBatches. Every chunk carries
+// the full cluster context.
+func (c *Client) NewEventMetricsBatches(clusterID, cycleID string, clusterContext map[string]interface{}, eventMetrics []collector.ResourceMetrics) ([]*Batch, error) {
+	resources := toEventResources(eventMetrics)
+
+	sizes := make([]int, len(resources))
+	for i := range resources {
+		item, err := json.Marshal(resources[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event metrics: %w", err)
+		}
+		sizes[i] = len(item)
+	}
+
+	spans := c.split(len(resources), func(i int) int { return sizes[i] })
+	collectedAt := time.Now().UTC()
+	batches := make([]*Batch, 0, len(spans))
+	for index, sp := range spans {
+		payload := EventMetricsPayload{
+			ClusterID:   clusterID,
+			Context:     clusterContext,
+			CollectedAt: collectedAt,
+			Resources:   resources[sp.start:sp.end],
+		}
+
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event metrics: %w", err)
+		}
+		batches = append(batches, newBatch(eventMetricsPath, clusterID, cycleID, index, len(spans), data, collectedAt))
+	}
+
+	return batches, nil
+}
+
+// toEventResources converts event ResourceMetrics to the EventResource format
+func toEventResources(eventMetrics []collector.ResourceMetrics) []EventResource {
 	// Convert ResourceMetrics to EventResource format
 	resources := make([]EventResource, 0, len(eventMetrics))
-	
+
 	for _, metric := range eventMetrics {
 		// Skip non-event resources
 		if metric.Kind != "Event" {
 			continue
 		}
-		
+
 		// Check if status is nil
 		if metric.Status == nil {
 			continue
 		}
-		
+
 		// Use the status map directly
 		status := metric.Status
-		
+
 		// Extract source information
 		sourceMap, _ := status["source"].(map[string]string)
 		source := EventSource{
 			Component: sourceMap["component"],
 			Host:      sourceMap["host"],
 		}
-		
+
 		// Extract involved object information
 		involvedMap, _ := status["involvedObject"].(map[string]string)
 		involved := EventInvolvedObject{
@@ -158,7 +265,7 @@ func (c *Client) SendEventMetrics(ctx context.Context, clusterID string, cluster
 			Name:      involvedMap["name"],
 			UID:       involvedMap["uid"],
 		}
-		
+
 		// Extract event metrics
 		eventMetrics := EventMetrics{
 			Count:           getIntFromMap(status, "count"),
@@ -167,7 +274,7 @@ func (c *Client) SendEventMetrics(ctx context.Context, clusterID string, cluster
 			DurationSeconds: getInt64FromMap(status, "durationSeconds"),
 			Severity:        getStringFromMap(status, "severity"),
 		}
-		
+
 		// Create event resource
 		eventResource := EventResource{
 			Namespace:      metric.Namespace,
@@ -185,52 +292,11 @@ func (c *Client) SendEventMetrics(ctx context.Context, clusterID string, cluster
 			},
 			Metrics: eventMetrics,
 		}
-		
+
 		resources = append(resources, eventResource)
 	}
-	
-	// Create payload
-	payload := EventMetricsPayload{
-		ClusterID:   clusterID,
-		Context:     clusterContext,
-		CollectedAt: time.Now().UTC(),
-		Resources:   resources,
-	}
-	
-	// Marshal payload
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event metrics: %w", err)
-	}
-	
-	// Create request
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/v1/metrics/events", c.baseURL),
-		bytes.NewReader(data),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create event metrics request: %w", err)
-	}
-	
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	
-	// Send request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send event metrics: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code for event metrics: %d", resp.StatusCode)
-	}
-	
-	return nil
+
+	return resources
 }
 
 // Helper functions for extracting values from maps
@@ -277,28 +343,12 @@ func getInt64FromMap(m map[string]interface{}, key string) int64 {
 
 // GetClusterConfig retrieves cluster-specific configuration from the SaaS platform
 func (c *Client) GetClusterConfig(ctx context.Context, clusterID string) (*ClusterConfig, error) {
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		fmt.Sprintf("%s/v1/clusters/%s/config", c.baseURL, clusterID),
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("X-API-Key", c.apiKey)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/v1/clusters/%s/config", clusterID), nil, nil))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster config: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
 	var config ClusterConfig
 	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -307,6 +357,73 @@ func (c *Client) GetClusterConfig(ctx context.Context, clusterID string) (*Clust
 	return &config, nil
 }
 
+// post sends a JSON payload, compressing it if configured. When the server
+// rejects the compressed body with 415 the payload is resent uncompressed and
+// compression stays disabled for the lifetime of the client.
+func (c *Client) post(ctx context.Context, batch *Batch) error {
+	headers := map[string]string{}
+	if batch.IdempotencyKey != "" {
+		headers["Idempotency-Key"] = batch.IdempotencyKey
+	}
+
+	if c.compression != "" && !c.compressionRejected.Load() {
+		body, err := compress(c.compression, batch.Body)
+		if err != nil {
+			return err
+		}
+
+		compressedHeaders := map[string]string{"Content-Encoding": string(c.compression)}
+		for k, v := range headers {
+			compressedHeaders[k] = v
+		}
+
+		resp, err := c.do(ctx, c.newRequest(ctx, http.MethodPost, batch.Path, body, compressedHeaders))
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnsupportedMediaType {
+			return err
+		}
+		c.compressionRejected.Store(true)
+	}
+
+	resp, err := c.do(ctx, c.newRequest(ctx, http.MethodPost, batch.Path, batch.Body, headers))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// newRequest returns a request factory for c.do. The body is re-read on
+// every call so that retried requests carry the full payload.
+func (c *Client) newRequest(ctx context.Context, method, path string, body []byte, headers map[string]string) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("X-API-Key", c.apiKey)
+		return req, nil
+	}
+}
+
 // GetBaseURL returns the base URL of the API
 func (c *Client) GetBaseURL() string {
 	return c.baseURL
@@ -349,6 +466,7 @@ type AlertingRule struct {
 type CustomMetricConfig struct {
 	Name       string            `json:"name"`
 	Query      string            `json:"query"`
+	Resource   string            `json:"resource,omitempty"`
 	Labels     map[string]string `json:"labels"`
 	Interval   time.Duration     `json:"interval"`
 	Aggregator string            `json:"aggregator"`
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies the algorithm used to compress request bodies
+type Compression string
+
+const (
+	// CompressionNone sends request bodies uncompressed
+	CompressionNone Compression = "none"
+
+	// CompressionGzip compresses request bodies with gzip
+	CompressionGzip Compression = "gzip"
+
+	// CompressionZstd compresses request bodies with zstd
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderErr  error
+)
+
+// compress encodes payload with the given algorithm
+func compress(algorithm Compression, payload []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(payload); err != nil {
+			return nil, fmt.Errorf("failed to gzip payload: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to gzip payload: %w", err)
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		// EncodeAll is safe for concurrent use, so one encoder is shared
+		zstdEncoderOnce.Do(func() {
+			zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil)
+		})
+		if zstdEncoderErr != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", zstdEncoderErr)
+		}
+		return zstdEncoder.EncodeAll(payload, make([]byte, 0, len(payload)/4)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %q", algorithm)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeBody(t *testing.T, r *http.Request) []collector.ResourceMetrics {
+	var reader io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		defer gz.Close()
+		reader = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		assert.NoError(t, err)
+		defer zr.Close()
+		reader = zr
+	}
+
+	var received []collector.ResourceMetrics
+	assert.NoError(t, json.NewDecoder(reader).Decode(&received))
+	return received
+}
+
+func TestClient_SendMetrics_Compression(t *testing.T) {
+	metrics := []collector.ResourceMetrics{
+		{Name: "test-pod", Namespace: "default", Kind: "Pod"},
+		{Name: "test-node", Kind: "Node"},
+	}
+
+	for _, algorithm := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			var received []collector.ResourceMetrics
+			var encoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				encoding = r.Header.Get("Content-Encoding")
+				received = decodeBody(t, r)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := NewClient(ClientConfig{
+				BaseURL:            server.URL,
+				Timeout:            5 * time.Second,
+				CompressionEnabled: true,
+				Compression:        algorithm,
+			})
+
+			err := client.SendMetrics(context.Background(), metrics)
+			assert.NoError(t, err)
+			assert.Equal(t, string(algorithm), encoding)
+			assert.Len(t, received, 2)
+			assert.Equal(t, "test-pod", received[0].Name)
+		})
+	}
+}
+
+func TestClient_SendMetrics_CompressionFallback(t *testing.T) {
+	var requests atomic.Int32
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		assert.Len(t, decodeBody(t, r), 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:            server.URL,
+		Timeout:            5 * time.Second,
+		CompressionEnabled: true,
+		Compression:        CompressionZstd,
+	})
+
+	metrics := []collector.ResourceMetrics{{Name: "test-pod", Kind: "Pod"}}
+	assert.NoError(t, client.SendMetrics(context.Background(), metrics))
+	assert.NoError(t, client.SendMetrics(context.Background(), metrics))
+
+	// The second send must skip compression after the first was rejected
+	assert.Equal(t, []string{"zstd", "", ""}, encodings)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_SendMetrics_CompressionDisabled(t *testing.T) {
+	var encoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:     server.URL,
+		Timeout:     5 * time.Second,
+		Compression: CompressionGzip,
+	})
+
+	assert.NoError(t, client.SendMetrics(context.Background(), nil))
+	assert.Empty(t, encoding)
+}
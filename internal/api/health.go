@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Health describes the outcome of the most recent API requests
+type Health struct {
+	// LastRequest is when the last request completed
+	LastRequest time.Time
+
+	// LastSuccess is when a request last succeeded
+	LastSuccess time.Time
+
+	// LastError is the error of the last request, nil if it succeeded
+	LastError error
+}
+
+// Health returns the outcome of the most recent requests. Requests cancelled
+// by their context are not recorded.
+func (c *Client) Health() Health {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	return c.health
+}
+
+func (c *Client) recordHealth(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	now := time.Now()
+	c.health.LastRequest = now
+	c.health.LastError = err
+	if err == nil {
+		c.health.LastSuccess = now
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultMaxRetryWait caps the exponential backoff between attempts
+	defaultMaxRetryWait = 30 * time.Second
+
+	// maxErrorBodyBytes limits how much of an error response body is kept
+	maxErrorBodyBytes = 1024
+)
+
+// APIError is returned when the HakonGo API answers with a non-2xx status code
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d: %s", e.StatusCode, e.Body)
+}
+
+// RetryError is returned once the client gives up on a request. It carries the
+// number of attempts made and wraps the error of the last attempt.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("request failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying.
+// Transport errors, including attempts that exceed the client timeout, 408,
+// 429 and 5xx responses (except 501) are retryable; everything else,
+// including the cancellation of the caller's context, is permanent.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// A timed out attempt also matches context.DeadlineExceeded, but comes
+	// wrapped in the *url.Error of the HTTP client. do reports the end of the
+	// caller's context as the bare context error.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusTooManyRequests:
+			return true
+		case apiErr.StatusCode == http.StatusNotImplemented:
+			return false
+		default:
+			return apiErr.StatusCode >= 500
+		}
+	}
+
+	// Anything else came from the transport (connection refused, reset, timeout)
+	return true
+}
+
+// IsAuthError reports whether err is an authentication or authorization
+// failure, which is fixed by rotating the API key rather than the payload
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// do executes the request produced by newRequest, retrying transient failures
+// with jittered exponential backoff. newRequest is called once per attempt so
+// that request bodies can be replayed. On success the caller owns resp.Body.
+// The outcome is recorded in the client's Health.
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	resp, err := c.doWithRetry(ctx, newRequest)
+	c.recordHealth(err)
+	return resp, err
+}
+
+func (c *Client) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil {
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp, nil
+			}
+			err = newAPIError(resp)
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		if !IsRetryable(err) || attempt > c.maxRetries {
+			return nil, &RetryError{Attempts: attempt, Err: err}
+		}
+
+		wait := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			// Retrying before the server asked us to would just be rejected
+			// again, so give up instead if the requested wait is too long.
+			if apiErr.RetryAfter > c.maxRetryWait {
+				return nil, &RetryError{Attempts: attempt, Err: err}
+			}
+			wait = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &RetryError{Attempts: attempt, Err: err}
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before the next attempt using "equal jitter":
+// half of the exponential delay is fixed and the other half is random.
+func (c *Client) backoff(attempt int) time.Duration {
+	if c.retryWait <= 0 {
+		return 0
+	}
+
+	d := c.retryWait << (attempt - 1)
+	if d <= 0 || d > c.maxRetryWait {
+		d = c.maxRetryWait
+	}
+
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// newAPIError builds an APIError from resp and closes its body
+func newAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return apiErr
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns zero if the header is missing or malformed.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SendMetrics_RetriesTransientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		APIKey:            "test-api-key",
+		Timeout:           5 * time.Second,
+		MaxRetries:        3,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	err := client.SendMetrics(context.Background(), []collector.ResourceMetrics{{Name: "test-pod", Kind: "Pod"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestClient_SendMetrics_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Timeout:           5 * time.Second,
+		MaxRetries:        2,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	err := client.SendMetrics(context.Background(), nil)
+	assert.Error(t, err)
+
+	var retryErr *RetryError
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Equal(t, 3, retryErr.Attempts)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestClient_SendMetrics_DoesNotRetryPermanentErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Timeout:           5 * time.Second,
+		MaxRetries:        5,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	err := client.SendMetrics(context.Background(), nil)
+	assert.Error(t, err)
+	assert.False(t, IsRetryable(err))
+
+	var retryErr *RetryError
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Equal(t, 1, retryErr.Attempts)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClient_SendMetrics_RetriesTimedOutAttempts(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Timeout:           50 * time.Millisecond,
+		MaxRetries:        1,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	err := client.SendMetrics(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestClient_SendMetrics_StopsWhenContextEnds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Timeout:           5 * time.Second,
+		MaxRetries:        5,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.SendMetrics(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, IsRetryable(err))
+
+	var retryErr *RetryError
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Equal(t, 1, retryErr.Attempts)
+}
+
+func TestClient_SendMetrics_HonorsRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	var first time.Time
+	var second time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		second = time.Now()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Timeout:           5 * time.Second,
+		MaxRetries:        1,
+		RetryWaitDuration: time.Millisecond,
+	})
+
+	err := client.SendMetrics(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, second.Sub(first), 900*time.Millisecond)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestClient_Backoff(t *testing.T) {
+	client := NewClient(ClientConfig{RetryWaitDuration: 100 * time.Millisecond})
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := client.backoff(attempt)
+		expected := min(100*time.Millisecond<<(attempt-1), defaultMaxRetryWait)
+		assert.GreaterOrEqual(t, d, expected/2)
+		assert.LessOrEqual(t, d, expected)
+	}
+}
+
+func TestClient_Health(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{BaseURL: server.URL})
+	assert.True(t, client.Health().LastRequest.IsZero())
+
+	assert.NoError(t, client.SendMetrics(context.Background(), nil))
+	health := client.Health()
+	assert.NoError(t, health.LastError)
+	assert.False(t, health.LastSuccess.IsZero())
+
+	status = http.StatusForbidden
+	assert.Error(t, client.SendMetrics(context.Background(), nil))
+	health = client.Health()
+	assert.True(t, IsAuthError(health.LastError))
+	assert.True(t, health.LastSuccess.Before(health.LastRequest) || health.LastSuccess.Equal(health.LastRequest))
+}
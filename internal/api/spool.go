@@ -0,0 +1,362 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// spoolFileExt is the extension of persisted batch files
+	spoolFileExt = ".batch"
+
+	// DefaultSpoolMaxBytes is used when SpoolConfig.MaxBytes is not set
+	DefaultSpoolMaxBytes = 512 << 20
+
+	// DefaultSpoolMaxAge is used when SpoolConfig.MaxAge is not set
+	DefaultSpoolMaxAge = 24 * time.Hour
+)
+
+// SpoolConfig contains configuration for the on-disk spool
+type SpoolConfig struct {
+	// Dir is the directory batches are persisted to
+	Dir string
+
+	// MaxBytes caps the total size of spooled batches. When exceeded the
+	// oldest batches are evicted first.
+	MaxBytes int64
+
+	// MaxAge is how long a batch is kept before it is evicted
+	MaxAge time.Duration
+}
+
+// SpoolStats describes the current contents of the spool
+type SpoolStats struct {
+	Batches int
+	Bytes   int64
+	Oldest  time.Time
+
+	// Evicted counts batches removed because of size or age limits
+	Evicted int64
+
+	// Dropped counts batches the API rejected permanently
+	Dropped int64
+}
+
+// Spool is a write-ahead log of outbound batches. Every batch is persisted
+// before it is sent and only removed once the API has accepted it, so
+// metrics survive API outages and connector restarts. Batches are always
+// delivered in the order they were spooled.
+type Spool struct {
+	// drainMu serializes deliveries so batches are never sent out of order
+	drainMu sync.Mutex
+
+	// mu guards the client, the limits, the sequence counter, the directory
+	// contents and the stats
+	mu      sync.Mutex
+	client  *Client
+	config  SpoolConfig
+	nextSeq uint64
+	evicted int64
+	dropped int64
+}
+
+type spoolEntry struct {
+	seq     uint64
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewSpool creates a spool in config.Dir, picking up any batches left over
+// from a previous run
+func NewSpool(client *Client, config SpoolConfig) (*Spool, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("spool directory is required")
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultSpoolMaxAge
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		client: client,
+		config: config,
+	}
+
+	// Remove temporary files left behind by a crash mid-write
+	if tmpFiles, err := filepath.Glob(filepath.Join(config.Dir, ".tmp-*")); err == nil {
+		for _, f := range tmpFiles {
+			os.Remove(f)
+		}
+	}
+
+	entries, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		s.nextSeq = entries[len(entries)-1].seq + 1
+	}
+
+	return s, nil
+}
+
+// Update replaces the client and the limits of the spool. A drain in
+// progress finishes with the previous client. The directory cannot change.
+func (s *Spool) Update(client *Client, config SpoolConfig) error {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultSpoolMaxAge
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if config.Dir != s.config.Dir {
+		return fmt.Errorf("spool directory cannot change from %s to %s", s.config.Dir, config.Dir)
+	}
+	s.client = client
+	s.config.MaxBytes = config.MaxBytes
+	s.config.MaxAge = config.MaxAge
+	return nil
+}
+
+// Dir returns the directory batches are persisted to. It never changes.
+func (s *Spool) Dir() string {
+	return s.config.Dir
+}
+
+// Send persists the batches and then drains the spool, so they are
+// delivered after any batches still waiting from earlier failures. An error
+// means some batches are still spooled and will be retried by a later drain.
+func (s *Spool) Send(ctx context.Context, batches ...*Batch) error {
+	for _, batch := range batches {
+		if err := s.Enqueue(batch); err != nil {
+			return err
+		}
+	}
+	_, err := s.Drain(ctx)
+	return err
+}
+
+// Enqueue persists a batch to disk and applies the size and age limits
+func (s *Spool) Enqueue(batch *Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := fmt.Sprintf("%020d%s", s.nextSeq, spoolFileExt)
+	if err := writeFileAtomic(filepath.Join(s.config.Dir, name), data); err != nil {
+		return fmt.Errorf("failed to spool batch: %w", err)
+	}
+	s.nextSeq++
+
+	return s.evictLocked(time.Now())
+}
+
+// Drain delivers spooled batches oldest first and returns how many were
+// sent. It stops at the first batch that fails transiently so that ordering
+// is preserved. Batches the API rejects permanently are dropped, except for
+// authentication failures which are kept until the API key is fixed.
+func (s *Spool) Drain(ctx context.Context) (int, error) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.mu.Lock()
+	client := s.client
+	err := s.evictLocked(time.Now())
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := s.list()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		batch, err := readBatch(entry.path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Evicted by a concurrent Enqueue
+			continue
+		}
+		if err != nil {
+			s.drop(entry)
+			continue
+		}
+
+		if err := client.SendBatch(ctx, batch); err != nil {
+			if IsRetryable(err) || IsAuthError(err) || ctx.Err() != nil {
+				return sent, err
+			}
+			s.drop(entry)
+			continue
+		}
+
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return sent, fmt.Errorf("failed to remove delivered batch: %w", err)
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// Stats returns a summary of the spool contents
+func (s *Spool) Stats() (SpoolStats, error) {
+	entries, err := s.list()
+	if err != nil {
+		return SpoolStats{}, err
+	}
+
+	s.mu.Lock()
+	stats := SpoolStats{
+		Batches: len(entries),
+		Evicted: s.evicted,
+		Dropped: s.dropped,
+	}
+	s.mu.Unlock()
+
+	for _, entry := range entries {
+		stats.Bytes += entry.size
+		if stats.Oldest.IsZero() || entry.modTime.Before(stats.Oldest) {
+			stats.Oldest = entry.modTime
+		}
+	}
+
+	return stats, nil
+}
+
+// evictLocked removes batches older than MaxAge and then the oldest batches
+// until the spool fits in MaxBytes. s.mu must be held.
+func (s *Spool) evictLocked(now time.Time) error {
+	entries, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	for _, entry := range entries {
+		if now.Sub(entry.modTime) <= s.config.MaxAge && total <= s.config.MaxBytes {
+			break
+		}
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to evict spooled batch: %w", err)
+		}
+		total -= entry.size
+		s.evicted++
+	}
+
+	return nil
+}
+
+// drop removes a batch that can never be delivered
+func (s *Spool) drop(entry spoolEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(entry.path); err == nil {
+		s.dropped++
+	}
+}
+
+// list returns the spooled batches ordered by sequence number
+func (s *Spool) list() ([]spoolEntry, error) {
+	dirEntries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	entries := make([]spoolEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, spoolFileExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			// Removed since ReadDir
+			continue
+		}
+		entries = append(entries, spoolEntry{
+			seq:     seq,
+			path:    filepath.Join(s.config.Dir, name),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	return entries, nil
+}
+
+func readBatch(path string) (*Batch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to decode spooled batch %s: %w", path, err)
+	}
+	return &batch, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+// so a crash never leaves a partially written batch behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBatch(t *testing.T, client *Client, metrics []collector.ResourceMetrics) *Batch {
+	batches, err := client.NewMetricsBatches("test-cluster", NewCycleID(), metrics)
+	assert.NoError(t, err)
+	assert.Len(t, batches, 1)
+	return batches[0]
+}
+
+func TestSpool_ReplaysInOrderAfterOutage(t *testing.T) {
+	var available atomic.Bool
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var metrics []collector.ResourceMetrics
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&metrics))
+		mu.Lock()
+		received = append(received, metrics[0].Name)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{BaseURL: server.URL, Timeout: 5 * time.Second})
+	dir := t.TempDir()
+	spool, err := NewSpool(client, SpoolConfig{Dir: dir})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"first", "second"} {
+		batch := testBatch(t, client, []collector.ResourceMetrics{{Name: name, Kind: "Pod"}})
+		assert.Error(t, spool.Send(context.Background(), batch))
+	}
+
+	stats, err := spool.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Batches)
+
+	// A restarted connector picks up the batches left on disk
+	spool, err = NewSpool(client, SpoolConfig{Dir: dir})
+	assert.NoError(t, err)
+
+	available.Store(true)
+	batch := testBatch(t, client, []collector.ResourceMetrics{{Name: "third", Kind: "Pod"}})
+	assert.NoError(t, spool.Send(context.Background(), batch))
+
+	assert.Equal(t, []string{"first", "second", "third"}, received)
+
+	stats, err = spool.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Batches)
+}
+
+func TestSpool_DropsPermanentlyRejectedBatches(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{BaseURL: server.URL, Timeout: 5 * time.Second})
+	spool, err := NewSpool(client, SpoolConfig{Dir: t.TempDir()})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"bad", "good"} {
+		batch := testBatch(t, client, []collector.ResourceMetrics{{Name: name, Kind: "Pod"}})
+		assert.NoError(t, spool.Enqueue(batch))
+	}
+
+	sent, err := spool.Drain(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	stats, err := spool.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Batches)
+	assert.Equal(t, int64(1), stats.Dropped)
+}
+
+func TestSpool_EvictsOldestWhenFull(t *testing.T) {
+	client := NewClient(ClientConfig{BaseURL: "http://127.0.0.1:0"})
+	batch := testBatch(t, client, []collector.ResourceMetrics{{Name: "test-pod", Kind: "Pod"}})
+	data, err := json.Marshal(batch)
+	assert.NoError(t, err)
+
+	// Room for two batches
+	spool, err := NewSpool(client, SpoolConfig{Dir: t.TempDir(), MaxBytes: int64(2*len(data) + 1)})
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, spool.Enqueue(batch))
+	}
+
+	stats, err := spool.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Batches)
+	assert.Equal(t, int64(3), stats.Evicted)
+
+	entries, err := spool.list()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), entries[0].seq)
+	assert.Equal(t, uint64(4), entries[1].seq)
+}
+
+func TestSpool_EvictsExpiredBatches(t *testing.T) {
+	client := NewClient(ClientConfig{BaseURL: "http://127.0.0.1:0"})
+	spool, err := NewSpool(client, SpoolConfig{Dir: t.TempDir(), MaxAge: time.Hour})
+	assert.NoError(t, err)
+
+	batch := testBatch(t, client, nil)
+	assert.NoError(t, spool.Enqueue(batch))
+
+	spool.mu.Lock()
+	assert.NoError(t, spool.evictLocked(time.Now().Add(2*time.Hour)))
+	spool.mu.Unlock()
+
+	stats, err := spool.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Batches)
+	assert.Equal(t, int64(1), stats.Evicted)
+}
+
+func TestSpool_UpdateSwapsClient(t *testing.T) {
+	var oldHits, newHits atomic.Int32
+	oldServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oldHits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer oldServer.Close()
+	newServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newHits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer newServer.Close()
+
+	dir := t.TempDir()
+	oldClient := NewClient(ClientConfig{BaseURL: oldServer.URL})
+	spool, err := NewSpool(oldClient, SpoolConfig{Dir: dir})
+	assert.NoError(t, err)
+	assert.Error(t, spool.Send(context.Background(), testBatch(t, oldClient, nil)))
+
+	newClient := NewClient(ClientConfig{BaseURL: newServer.URL})
+	assert.Error(t, spool.Update(newClient, SpoolConfig{Dir: t.TempDir()}))
+	assert.NoError(t, spool.Update(newClient, SpoolConfig{Dir: dir, MaxBytes: 1 << 20}))
+
+	sent, err := spool.Drain(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, int32(1), newHits.Load())
+}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrCircuitOpen is returned instead of running a collector whose circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrorClass groups collection errors by their likely cause
+type ErrorClass string
+
+const (
+	ErrorClassForbidden    ErrorClass = "Forbidden"
+	ErrorClassUnauthorized ErrorClass = "Unauthorized"
+	ErrorClassNotFound     ErrorClass = "NotFound"
+	ErrorClassTimeout      ErrorClass = "Timeout"
+	ErrorClassThrottled    ErrorClass = "Throttled"
+	ErrorClassUnavailable  ErrorClass = "Unavailable"
+	ErrorClassUnknown      ErrorClass = "Unknown"
+)
+
+// ClassifyError returns the class of a collection error
+func ClassifyError(err error) ErrorClass {
+	var netErr net.Error
+	switch {
+	case apierrors.IsForbidden(err):
+		return ErrorClassForbidden
+	case apierrors.IsUnauthorized(err):
+		return ErrorClassUnauthorized
+	case apierrors.IsNotFound(err):
+		return ErrorClassNotFound
+	case apierrors.IsTooManyRequests(err):
+		return ErrorClassThrottled
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err), errors.As(err, &netErr):
+		return ErrorClassUnavailable
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// BreakerConfig contains configuration for a circuit breaker
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+
+	// BaseBackoff is how long the breaker stays open after it opens. It
+	// doubles with every further consecutive failure.
+	BaseBackoff time.Duration
+
+	// MaxBackoff caps how long the breaker stays open
+	MaxBackoff time.Duration
+}
+
+// DefaultBreakerConfig returns the breaker settings used for collectors
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 3,
+		BaseBackoff:      time.Minute,
+		MaxBackoff:       30 * time.Minute,
+	}
+}
+
+// Health describes the recent outcomes of a collector
+type Health struct {
+	// ConsecutiveFailures counts failed runs since the last success
+	ConsecutiveFailures int
+
+	// TotalFailures counts all failed runs
+	TotalFailures int64
+
+	// LastError and LastErrorClass describe the most recent failure
+	LastError      error
+	LastErrorClass ErrorClass
+
+	LastSuccess time.Time
+	LastFailure time.Time
+
+	// LastItemCount is the number of resources returned by the last successful run
+	LastItemCount int
+
+	// Degraded is the *DegradedError of the last successful run, nil when it
+	// collected all of its data
+	Degraded error
+
+	// OpenUntil is when the breaker lets the next run through. It is zero
+	// while the breaker is closed.
+	OpenUntil time.Time
+}
+
+// Breaker tracks the health of a collector and stops running it for a while
+// after repeated failures. Once the backoff has elapsed one trial run is let
+// through: a success closes the breaker, a failure opens it again for twice
+// as long.
+type Breaker struct {
+	config BreakerConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	health Health
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(config BreakerConfig) *Breaker {
+	return &Breaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen while the breaker is open
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until := b.health.OpenUntil; b.now().Before(until) {
+		return fmt.Errorf("%w until %s after %d consecutive failures",
+			ErrCircuitOpen, until.Format(time.RFC3339), b.health.ConsecutiveFailures)
+	}
+	return nil
+}
+
+// Record updates the breaker with the outcome of a run that returned items
+// resources. Degraded runs count as successes.
+func (b *Breaker) Record(items int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if err == nil || IsDegraded(err) {
+		b.health.ConsecutiveFailures = 0
+		b.health.OpenUntil = time.Time{}
+		b.health.LastSuccess = now
+		b.health.LastItemCount = items
+		b.health.Degraded = err
+		return
+	}
+
+	b.health.ConsecutiveFailures++
+	b.health.TotalFailures++
+	b.health.LastError = err
+	b.health.LastErrorClass = ClassifyError(err)
+	b.health.LastFailure = now
+
+	if excess := b.health.ConsecutiveFailures - b.config.FailureThreshold; excess >= 0 {
+		backoff := b.config.BaseBackoff
+		for i := 0; i < excess && backoff < b.config.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		backoff = min(backoff, b.config.MaxBackoff)
+		b.health.OpenUntil = now.Add(backoff)
+	}
+}
+
+// Health returns the current health
+func (b *Breaker) Health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.health
+}
+
+// breakerCollector guards a collector with a circuit breaker
+type breakerCollector struct {
+	Collector
+	breaker *Breaker
+}
+
+// WithBreaker wraps c so that its runs are recorded by b and skipped while
+// b is open. Runs cancelled through their context are not recorded.
+func WithBreaker(c Collector, b *Breaker) Collector {
+	return &breakerCollector{Collector: c, breaker: b}
+}
+
+// Collect runs the wrapped collector unless the breaker is open
+func (c *breakerCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	metrics, err := c.Collector.Collect(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	c.breaker.Record(len(metrics), err)
+	return metrics, err
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type failingCollector struct {
+	err   error
+	calls int
+}
+
+func (c *failingCollector) Collect(context.Context) ([]ResourceMetrics, error) {
+	c.calls++
+	return nil, c.err
+}
+func (c *failingCollector) Name() string        { return "failing" }
+func (c *failingCollector) Description() string { return "always fails" }
+
+func TestClassifyError(t *testing.T) {
+	gr := schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}
+	assert.Equal(t, ErrorClassForbidden, ClassifyError(apierrors.NewForbidden(gr, "", errors.New("rbac"))))
+	assert.Equal(t, ErrorClassTimeout, ClassifyError(context.DeadlineExceeded))
+	assert.Equal(t, ErrorClassThrottled, ClassifyError(apierrors.NewTooManyRequests("slow down", 1)))
+	assert.Equal(t, ErrorClassUnknown, ClassifyError(errors.New("boom")))
+}
+
+func TestBreaker_OpensAndBacksOff(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2, BaseBackoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	b.now = func() time.Time { return now }
+
+	inner := &failingCollector{err: errors.New("boom")}
+	c := WithBreaker(inner, b)
+
+	// Below the threshold every run goes through
+	_, err := c.Collect(context.Background())
+	assert.EqualError(t, err, "boom")
+	assert.NoError(t, b.Allow())
+
+	// The second failure opens the breaker
+	c.Collect(context.Background())
+	_, err = c.Collect(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, inner.calls)
+	assert.Equal(t, now.Add(time.Minute), b.Health().OpenUntil)
+
+	// A failed trial run doubles the backoff, capped at MaxBackoff
+	now = now.Add(time.Minute)
+	c.Collect(context.Background())
+	assert.Equal(t, now.Add(2*time.Minute), b.Health().OpenUntil)
+	now = now.Add(2 * time.Minute)
+	c.Collect(context.Background())
+	assert.Equal(t, now.Add(3*time.Minute), b.Health().OpenUntil)
+
+	health := b.Health()
+	assert.Equal(t, 4, health.ConsecutiveFailures)
+	assert.Equal(t, ErrorClassUnknown, health.LastErrorClass)
+
+	// A successful trial run closes the breaker
+	now = now.Add(3 * time.Minute)
+	inner.err = nil
+	_, err = c.Collect(context.Background())
+	assert.NoError(t, err)
+	health = b.Health()
+	assert.Zero(t, health.ConsecutiveFailures)
+	assert.True(t, health.OpenUntil.IsZero())
+	assert.Equal(t, int64(4), health.TotalFailures)
+	assert.Zero(t, health.LastItemCount)
+}
+
+func TestBreaker_DegradedRunsSucceed(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, BaseBackoff: time.Minute, MaxBackoff: time.Minute})
+	degraded := &DegradedError{Err: errors.New("node-2 unreachable")}
+
+	b.Record(3, degraded)
+	health := b.Health()
+	assert.Zero(t, health.ConsecutiveFailures)
+	assert.Equal(t, 3, health.LastItemCount)
+	assert.Equal(t, degraded, health.Degraded)
+	assert.NoError(t, b.Allow())
+
+	b.Record(4, nil)
+	assert.Nil(t, b.Health().Degraded)
+}
+
+func TestBreaker_IgnoresCancelledRuns(t *testing.T) {
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, BaseBackoff: time.Minute, MaxBackoff: time.Minute})
+	c := WithBreaker(&failingCollector{err: context.Canceled}, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Collect(ctx)
+
+	assert.Zero(t, b.Health().ConsecutiveFailures)
+	assert.NoError(t, b.Allow())
+}
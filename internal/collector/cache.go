@@ -0,0 +1,327 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// lastAppliedAnnotation holds a full copy of objects managed by kubectl apply
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Cache serves the Kubernetes objects the collectors read from shared
+// informers, so that collections do not list them from the API server. The
+// informer of a resource starts when the resource is first read, and keeps
+// its objects up to date until Stop is called. It outlives the collectors,
+// which are recreated on every reconcile.
+type Cache struct {
+	namespaces []string
+
+	// cluster watches cluster-scoped resources, and namespaced resources
+	// when no namespaces are given
+	cluster informers.SharedInformerFactory
+
+	// namespaced watches namespaced resources, one factory per namespace
+	namespaced []informers.SharedInformerFactory
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCache creates a cache. Namespaced resources are only watched in
+// namespaces, or in all namespaces when it is empty.
+func NewCache(kubeClient kubernetes.Interface, namespaces []string) *Cache {
+	c := &Cache{
+		namespaces: append([]string(nil), namespaces...),
+		cluster:    informers.NewSharedInformerFactoryWithOptions(kubeClient, 0, informers.WithTransform(trimObject)),
+		stop:       make(chan struct{}),
+	}
+	for _, namespace := range c.namespaces {
+		c.namespaced = append(c.namespaced, informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+			informers.WithNamespace(namespace), informers.WithTransform(trimObject)))
+	}
+	if len(c.namespaced) == 0 {
+		c.namespaced = []informers.SharedInformerFactory{c.cluster}
+	}
+	return c
+}
+
+// WatchedNamespaces returns the namespaces namespaced resources are watched
+// in, empty for all
+func (c *Cache) WatchedNamespaces() []string {
+	return c.namespaces
+}
+
+// Stop stops the informers and waits for them to finish
+func (c *Cache) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.cluster.Shutdown()
+		for _, factory := range c.namespaced {
+			if factory != c.cluster {
+				factory.Shutdown()
+			}
+		}
+	})
+}
+
+// Pods returns the pods of the watched namespaces
+func (c *Cache) Pods(ctx context.Context) ([]*corev1.Pod, error) {
+	return list[*corev1.Pod](ctx, c, "pods", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Pods().Informer()
+	})
+}
+
+// Services returns the services of the watched namespaces
+func (c *Cache) Services(ctx context.Context) ([]*corev1.Service, error) {
+	return list[*corev1.Service](ctx, c, "services", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Services().Informer()
+	})
+}
+
+// Endpoints returns the endpoints of the watched namespaces
+func (c *Cache) Endpoints(ctx context.Context) ([]*corev1.Endpoints, error) {
+	return list[*corev1.Endpoints](ctx, c, "endpoints", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Endpoints().Informer()
+	})
+}
+
+// PersistentVolumeClaims returns the claims of the watched namespaces
+func (c *Cache) PersistentVolumeClaims(ctx context.Context) ([]*corev1.PersistentVolumeClaim, error) {
+	return list[*corev1.PersistentVolumeClaim](ctx, c, "persistentvolumeclaims", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().PersistentVolumeClaims().Informer()
+	})
+}
+
+// Events returns the events of the watched namespaces
+func (c *Cache) Events(ctx context.Context) ([]*corev1.Event, error) {
+	return list[*corev1.Event](ctx, c, "events", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Events().Informer()
+	})
+}
+
+// Deployments returns the deployments of the watched namespaces
+func (c *Cache) Deployments(ctx context.Context) ([]*appsv1.Deployment, error) {
+	return list[*appsv1.Deployment](ctx, c, "deployments", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().Deployments().Informer()
+	})
+}
+
+// StatefulSets returns the stateful sets of the watched namespaces
+func (c *Cache) StatefulSets(ctx context.Context) ([]*appsv1.StatefulSet, error) {
+	return list[*appsv1.StatefulSet](ctx, c, "statefulsets", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().StatefulSets().Informer()
+	})
+}
+
+// DaemonSets returns the daemon sets of the watched namespaces
+func (c *Cache) DaemonSets(ctx context.Context) ([]*appsv1.DaemonSet, error) {
+	return list[*appsv1.DaemonSet](ctx, c, "daemonsets", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().DaemonSets().Informer()
+	})
+}
+
+// Ingresses returns the ingresses of the watched namespaces
+func (c *Cache) Ingresses(ctx context.Context) ([]*networkingv1.Ingress, error) {
+	return list[*networkingv1.Ingress](ctx, c, "ingresses", c.namespaced, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Networking().V1().Ingresses().Informer()
+	})
+}
+
+// Nodes returns the nodes of the cluster
+func (c *Cache) Nodes(ctx context.Context) ([]*corev1.Node, error) {
+	return list[*corev1.Node](ctx, c, "nodes", []informers.SharedInformerFactory{c.cluster}, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Nodes().Informer()
+	})
+}
+
+// PersistentVolumes returns the persistent volumes of the cluster
+func (c *Cache) PersistentVolumes(ctx context.Context) ([]*corev1.PersistentVolume, error) {
+	return list[*corev1.PersistentVolume](ctx, c, "persistentvolumes", []informers.SharedInformerFactory{c.cluster}, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().PersistentVolumes().Informer()
+	})
+}
+
+// Namespaces returns the namespaces of the cluster
+func (c *Cache) Namespaces(ctx context.Context) ([]*corev1.Namespace, error) {
+	return list[*corev1.Namespace](ctx, c, "namespaces", []informers.SharedInformerFactory{c.cluster}, func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Core().V1().Namespaces().Informer()
+	})
+}
+
+// ownerInformer is the resource and informer of an owner kind held by the
+// cache
+type ownerInformer struct {
+	resource string
+	informer func(informers.SharedInformerFactory) cache.SharedIndexInformer
+}
+
+// ownerInformers are the namespaced owner kinds Object serves from the cache
+var ownerInformers = map[schema.GroupKind]ownerInformer{
+	{Group: "apps", Kind: "ReplicaSet"}: {"replicasets", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().ReplicaSets().Informer()
+	}},
+	{Group: "apps", Kind: "Deployment"}: {"deployments", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().Deployments().Informer()
+	}},
+	{Group: "apps", Kind: "StatefulSet"}: {"statefulsets", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().StatefulSets().Informer()
+	}},
+	{Group: "apps", Kind: "DaemonSet"}: {"daemonsets", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Apps().V1().DaemonSets().Informer()
+	}},
+	{Group: "batch", Kind: "Job"}: {"jobs", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().Jobs().Informer()
+	}},
+	{Group: "batch", Kind: "CronJob"}: {"cronjobs", func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+		return f.Batch().V1().CronJobs().Informer()
+	}},
+}
+
+// Object returns the namespaced object of kind named name, nil when it does
+// not exist. cached is false when the cache does not hold objects of kind,
+// which then have to be read from the API server. Like the listed objects,
+// the object must not be modified.
+func (c *Cache) Object(ctx context.Context, kind schema.GroupKind, namespace, name string) (obj metav1.Object, cached bool, err error) {
+	owner, ok := ownerInformers[kind]
+	if !ok {
+		return nil, false, nil
+	}
+	watched, err := start(ctx, c, owner.resource, c.namespaced, owner.informer)
+	if err != nil {
+		return nil, true, err
+	}
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	for _, w := range watched {
+		item, exists, err := w.GetStore().GetByKey(key)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to get %s %s: %w", owner.resource, key, err)
+		}
+		if exists {
+			if o, ok := item.(metav1.Object); ok {
+				return o, true, nil
+			}
+		}
+	}
+	return nil, true, nil
+}
+
+// start starts the informer of a resource in each factory and waits for the
+// informers to sync
+func start(ctx context.Context, c *Cache, resource string, factories []informers.SharedInformerFactory, informer func(informers.SharedInformerFactory) cache.SharedIndexInformer) ([]cache.SharedIndexInformer, error) {
+	select {
+	case <-c.stop:
+		return nil, fmt.Errorf("failed to read %s: cache is stopped", resource)
+	default:
+	}
+
+	watched := make([]cache.SharedIndexInformer, len(factories))
+	synced := make([]cache.InformerSynced, len(factories))
+	for i, factory := range factories {
+		watched[i] = informer(factory)
+		synced[i] = watched[i].HasSynced
+		factory.Start(c.stop)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return nil, fmt.Errorf("failed to sync %s cache: %w", resource, context.Cause(ctx))
+	}
+	return watched, nil
+}
+
+// list starts the informer of a resource in each factory, waits for the
+// informers to sync and returns their objects ordered by namespace and name,
+// like a List call. The objects are shared with the cache and must not be
+// modified.
+func list[T metav1.Object](ctx context.Context, c *Cache, resource string, factories []informers.SharedInformerFactory, informer func(informers.SharedInformerFactory) cache.SharedIndexInformer) ([]T, error) {
+	watched, err := start(ctx, c, resource, factories, informer)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []T
+	for _, w := range watched {
+		for _, obj := range w.GetStore().List() {
+			if o, ok := obj.(T); ok {
+				result = append(result, o)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].GetNamespace() != result[j].GetNamespace() {
+			return result[i].GetNamespace() < result[j].GetNamespace()
+		}
+		return result[i].GetName() < result[j].GetName()
+	})
+	return result, nil
+}
+
+// trimObject drops the fields no collector reads before objects enter the
+// cache, to bound its memory use on large clusters
+func trimObject(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+		if annotations := accessor.GetAnnotations(); annotations[lastAppliedAnnotation] != "" {
+			trimmed := make(map[string]string, len(annotations)-1)
+			for k, v := range annotations {
+				if k != lastAppliedAnnotation {
+					trimmed[k] = v
+				}
+			}
+			accessor.SetAnnotations(trimmed)
+		}
+	}
+
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		// Only the names and resources of the containers are read
+		for i, container := range o.Spec.Containers {
+			o.Spec.Containers[i] = corev1.Container{
+				Name:      container.Name,
+				Image:     container.Image,
+				Resources: container.Resources,
+			}
+		}
+		o.Spec.InitContainers = nil
+		o.Spec.EphemeralContainers = nil
+		o.Spec.Volumes = nil
+		o.Spec.Affinity = nil
+		o.Spec.Tolerations = nil
+	case *corev1.Node:
+		// The image list of a node can hold hundreds of entries
+		o.Status.Images = nil
+		o.Status.VolumesAttached = nil
+		o.Status.VolumesInUse = nil
+	case *appsv1.Deployment:
+		o.Spec.Template.Spec = corev1.PodSpec{}
+	case *appsv1.StatefulSet:
+		o.Spec.Template.Spec = corev1.PodSpec{}
+		o.Spec.VolumeClaimTemplates = nil
+	case *appsv1.DaemonSet:
+		o.Spec.Template.Spec = corev1.PodSpec{}
+	case *appsv1.ReplicaSet:
+		// Only the owner references of replica sets, jobs and cron jobs are
+		// read, to resolve the workloads of pods
+		o.Spec.Template.Spec = corev1.PodSpec{}
+	case *batchv1.Job:
+		o.Spec.Template.Spec = corev1.PodSpec{}
+	case *batchv1.CronJob:
+		o.Spec.JobTemplate.Spec.Template.Spec = corev1.PodSpec{}
+	}
+	return obj, nil
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestCache creates a cache over a fake clientset holding objects
+func newTestCache(t testing.TB, objects ...runtime.Object) *Cache {
+	c := NewCache(fake.NewSimpleClientset(objects...), nil)
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func TestCache_NamespaceScoped(t *testing.T) {
+	pod := func(namespace, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	client := fake.NewSimpleClientset(
+		pod("team-b", "web-1"), pod("team-a", "web-2"), pod("team-a", "web-1"), pod("kube-system", "coredns"),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+	)
+	c := NewCache(client, []string{"team-a", "team-b"})
+	defer c.Stop()
+
+	pods, err := c.Pods(context.Background())
+	assert.NoError(t, err)
+	var names []string
+	for _, p := range pods {
+		names = append(names, p.Namespace+"/"+p.Name)
+	}
+	assert.Equal(t, []string{"team-a/web-1", "team-a/web-2", "team-b/web-1"}, names)
+
+	// Cluster-scoped resources are not limited to the namespaces
+	nodes, err := c.Nodes(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+}
+
+func TestCache_Stopped(t *testing.T) {
+	c := NewCache(fake.NewSimpleClientset(), nil)
+	c.Stop()
+
+	_, err := c.Pods(context.Background())
+	assert.Error(t, err)
+}
+
+func TestTrimObject(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "web-1",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+			Annotations:   map[string]string{lastAppliedAnnotation: "{}", "team": "a"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:      "app",
+				Env:       []corev1.EnvVar{{Name: "DEBUG", Value: "1"}},
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}},
+			}},
+			Volumes: []corev1.Volume{{Name: "config"}},
+		},
+	}
+	trimmed, err := trimObject(pod)
+	assert.NoError(t, err)
+	pod = trimmed.(*corev1.Pod)
+	assert.Nil(t, pod.ManagedFields)
+	assert.Equal(t, map[string]string{"team": "a"}, pod.Annotations)
+	assert.Nil(t, pod.Spec.Volumes)
+	assert.Nil(t, pod.Spec.Containers[0].Env)
+	assert.Equal(t, "app", pod.Spec.Containers[0].Name)
+	assert.Equal(t, int64(100), pod.Spec.Containers[0].Resources.Requests.Cpu().MilliValue())
+
+	node := &corev1.Node{Status: corev1.NodeStatus{Images: []corev1.ContainerImage{{Names: []string{"nginx"}}}}}
+	trimmed, _ = trimObject(node)
+	assert.Nil(t, trimmed.(*corev1.Node).Status.Images)
+
+	deploy := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}}}
+	trimmed, _ = trimObject(deploy)
+	assert.Empty(t, trimmed.(*appsv1.Deployment).Spec.Template.Spec.Containers)
+	assert.Equal(t, "web", trimmed.(*appsv1.Deployment).Spec.Template.Labels["app"])
+
+	cronJob := &batchv1.CronJob{}
+	cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers = []corev1.Container{{Name: "report"}}
+	trimmed, _ = trimObject(cronJob)
+	assert.Empty(t, trimmed.(*batchv1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers)
+}
@@ -0,0 +1,360 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/prometheus/common/model"
+)
+
+// Resource kinds custom metrics can be attached to. Values of Workload
+// series are reported under the kind of the workload, such as Deployment.
+const (
+	CustomResourcePod       = "Pod"
+	CustomResourceNamespace = "Namespace"
+	CustomResourceNode      = "Node"
+	CustomResourceWorkload  = "Workload"
+)
+
+// defaultWorkloadKind is the kind of Workload series without a
+// workload_kind label
+const defaultWorkloadKind = "Deployment"
+
+// Aggregators combining the series of a custom metric that map to the same resource
+const (
+	AggregatorSum   = "sum"
+	AggregatorAvg   = "avg"
+	AggregatorMin   = "min"
+	AggregatorMax   = "max"
+	AggregatorCount = "count"
+)
+
+// Labels identifying the resource a custom metric series belongs to.
+// CustomMetric.Labels maps them to the labels of the series.
+const (
+	customLabelNamespace    = "namespace"
+	customLabelPod          = "pod"
+	customLabelNode         = "node"
+	customLabelWorkload     = "workload"
+	customLabelWorkloadKind = "workload_kind"
+)
+
+// CustomMetric is a PromQL query whose results are attached to the
+// resources its series belong to
+type CustomMetric struct {
+	// Name is the key of the values in ResourceMetrics.CustomMetrics
+	Name string
+
+	// Query is the PromQL instant query
+	Query string
+
+	// Resource is the kind the results are attached to: Pod, Namespace,
+	// Node or Workload. When empty it is inferred from the labels of each
+	// series.
+	Resource string
+
+	// Labels maps the resource labels namespace, pod, node, workload and
+	// workload_kind to the series labels carrying them. Unmapped resource
+	// labels are read from series labels of the same name.
+	Labels map[string]string
+
+	// Aggregator combines series that map to the same resource: sum, avg,
+	// min, max or count. Defaults to sum.
+	Aggregator string
+
+	// Interval is the minimum time between two runs of the query. The
+	// query runs on every collection when zero.
+	Interval time.Duration
+}
+
+// RunTracker remembers when each custom metric query last succeeded. It
+// outlives the collectors, which are recreated on every reconcile.
+type RunTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// NewRunTracker creates an empty run tracker
+func NewRunTracker() *RunTracker {
+	return &RunTracker{last: make(map[string]time.Time)}
+}
+
+// Due reports whether the named query should run at now
+func (t *RunTracker) Due(name string, interval time.Duration, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.last[name]
+	return !ok || now.Sub(last) >= interval
+}
+
+// Mark records a successful run of the named query
+func (t *RunTracker) Mark(name string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[name] = now
+}
+
+// CustomMetricIndex holds the values of the last successful run of each
+// custom metric query, so they are attached to the records of the other
+// collectors. It outlives the collectors, which are recreated on every
+// reconcile.
+type CustomMetricIndex struct {
+	mu     sync.RWMutex
+	values map[string]map[customResource]float64
+}
+
+// NewCustomMetricIndex creates an empty custom metric index
+func NewCustomMetricIndex() *CustomMetricIndex {
+	return &CustomMetricIndex{values: make(map[string]map[customResource]float64)}
+}
+
+// Publish replaces the values of the named metric with those of a run
+func (x *CustomMetricIndex) Publish(name string, values map[customResource]float64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.values[name] = values
+}
+
+// Retain forgets the values of the metrics not in custom, such as those
+// removed from the configuration
+func (x *CustomMetricIndex) Retain(custom []CustomMetric) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for name := range x.values {
+		if !slices.ContainsFunc(custom, func(m CustomMetric) bool { return m.Name == name }) {
+			delete(x.values, name)
+		}
+	}
+}
+
+// Attach sets the CustomMetrics of the resources the published values
+// belong to. Resources without values are left unchanged.
+func (x *CustomMetricIndex) Attach(resources []ResourceMetrics) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.values) == 0 {
+		return
+	}
+	for i := range resources {
+		key := customResource{kind: resources[i].Kind, namespace: resources[i].Namespace, name: resources[i].Name}
+		if key.kind == CustomResourceNamespace {
+			key.namespace = ""
+		}
+		var custom map[string]float64
+		for name, values := range x.values {
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+			if custom == nil {
+				custom = maps.Clone(resources[i].CustomMetrics)
+				if custom == nil {
+					custom = make(map[string]float64)
+				}
+			}
+			custom[name] = value
+		}
+		if custom != nil {
+			resources[i].CustomMetrics = custom
+		}
+	}
+}
+
+// CustomMetricsCollector runs the custom metric queries and publishes their
+// values per resource to an index. It returns no records of its own.
+type CustomMetricsCollector struct {
+	prometheusClient *metrics.PrometheusClient
+	config           CollectorConfig
+	runs             *RunTracker
+	index            *CustomMetricIndex
+}
+
+// NewCustomMetricsCollector creates a collector for config.CustomMetrics.
+// runs may be nil, in which case every query runs on every collection. The
+// values of each successful query are published to index unless it is nil.
+func NewCustomMetricsCollector(prometheusClient *metrics.PrometheusClient, config CollectorConfig, runs *RunTracker, index *CustomMetricIndex) *CustomMetricsCollector {
+	if runs == nil {
+		runs = NewRunTracker()
+	}
+	return &CustomMetricsCollector{
+		prometheusClient: prometheusClient,
+		config:           config,
+		runs:             runs,
+		index:            index,
+	}
+}
+
+func (c *CustomMetricsCollector) Name() string { return "custom-metrics-collector" }
+
+func (c *CustomMetricsCollector) Description() string {
+	return "Runs custom PromQL queries and attaches the results to resources"
+}
+
+// customResource identifies the resource a custom metric value belongs to
+type customResource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (c *CustomMetricsCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
+	if len(c.config.CustomMetrics) == 0 {
+		return nil, nil
+	}
+	if c.prometheusClient == nil {
+		return nil, fmt.Errorf("custom metrics require Prometheus to be configured")
+	}
+
+	now := time.Now()
+	var errs []error
+	ran := 0
+	for _, m := range c.config.CustomMetrics {
+		if !c.runs.Due(m.Name, m.Interval, now) {
+			continue
+		}
+		ran++
+
+		result, err := c.prometheusClient.Query(ctx, m.Query, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("custom metric %s: %w", m.Name, err))
+			continue
+		}
+		c.runs.Mark(m.Name, now)
+
+		vector, ok := result.(model.Vector)
+		if !ok {
+			errs = append(errs, fmt.Errorf("custom metric %s: query returned %s, expected a vector", m.Name, result.Type()))
+			continue
+		}
+		if c.index != nil {
+			c.index.Publish(m.Name, aggregate(m, vector))
+		}
+	}
+
+	// Fail only when no query succeeded, so one broken query does not hide
+	// the others. Failed queries keep the values of their last run.
+	if len(errs) > 0 && len(errs) == ran {
+		return nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		return nil, &DegradedError{Err: errors.Join(errs...)}
+	}
+	return nil, nil
+}
+
+// aggregate maps the series of a query result to resources and combines
+// the values of each resource with the metric's aggregator. Series that
+// identify no resource are ignored.
+func aggregate(m CustomMetric, vector model.Vector) map[customResource]float64 {
+	samples := make(map[customResource][]float64)
+	for _, sample := range vector {
+		value := float64(sample.Value)
+		if math.IsNaN(value) {
+			continue
+		}
+		resource, ok := m.resourceOf(sample.Metric)
+		if !ok {
+			continue
+		}
+		samples[resource] = append(samples[resource], value)
+	}
+
+	result := make(map[customResource]float64, len(samples))
+	for resource, values := range samples {
+		result[resource] = combine(m.Aggregator, values)
+	}
+	return result
+}
+
+func combine(aggregator string, values []float64) float64 {
+	switch aggregator {
+	case AggregatorCount:
+		return float64(len(values))
+	case AggregatorMin, AggregatorMax:
+		result := values[0]
+		for _, v := range values[1:] {
+			if (aggregator == AggregatorMin && v < result) || (aggregator == AggregatorMax && v > result) {
+				result = v
+			}
+		}
+		return result
+	default:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if aggregator == AggregatorAvg {
+			return sum / float64(len(values))
+		}
+		return sum
+	}
+}
+
+// resourceOf returns the resource a series belongs to
+func (m CustomMetric) resourceOf(metric model.Metric) (customResource, bool) {
+	label := func(name string) string {
+		if mapped, ok := m.Labels[name]; ok {
+			name = mapped
+		}
+		return string(metric[model.LabelName(name)])
+	}
+	namespace := label(customLabelNamespace)
+
+	kind := m.Resource
+	if kind == "" {
+		switch {
+		case label(customLabelPod) != "":
+			kind = CustomResourcePod
+		case label(customLabelWorkload) != "":
+			kind = CustomResourceWorkload
+		case label(customLabelNode) != "":
+			kind = CustomResourceNode
+		case namespace != "":
+			kind = CustomResourceNamespace
+		default:
+			return customResource{}, false
+		}
+	}
+
+	var resource customResource
+	switch kind {
+	case CustomResourcePod:
+		resource = customResource{kind: kind, namespace: namespace, name: label(customLabelPod)}
+	case CustomResourceWorkload:
+		resource = customResource{kind: workloadKind(label(customLabelWorkloadKind)), namespace: namespace, name: label(customLabelWorkload)}
+	case CustomResourceNode:
+		resource = customResource{kind: kind, name: label(customLabelNode)}
+	case CustomResourceNamespace:
+		resource = customResource{kind: kind, name: namespace}
+	default:
+		return customResource{}, false
+	}
+
+	if resource.name == "" || (kind == CustomResourcePod || kind == CustomResourceWorkload) && namespace == "" {
+		return customResource{}, false
+	}
+	return resource, true
+}
+
+// workloadKind returns the kind the workload collector reports for the
+// value of a workload_kind label. Known kinds are matched regardless of
+// case.
+func workloadKind(value string) string {
+	if value == "" {
+		return defaultWorkloadKind
+	}
+	for _, kind := range workloadKinds {
+		if strings.EqualFold(kind, value) {
+			return kind
+		}
+	}
+	return value
+}
@@ -0,0 +1,193 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCustomPrometheus answers each query with the samples registered for it
+// and fails the queries it does not know
+func newCustomPrometheus(t *testing.T, results map[string][]map[string]interface{}) (*metrics.PrometheusClient, *atomic.Int64) {
+	var queries atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		result, ok := results[r.FormValue("query")]
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "error", "errorType": "bad_data", "error": "parse error",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := metrics.NewPrometheusClient(server.URL)
+	assert.NoError(t, err)
+	return client, &queries
+}
+
+func sample(labels map[string]string, value string) map[string]interface{} {
+	return map[string]interface{}{"metric": labels, "value": []interface{}{0, value}}
+}
+
+func TestCustomMetricsCollector_Collect(t *testing.T) {
+	promClient, _ := newCustomPrometheus(t, map[string][]map[string]interface{}{
+		"requests": {
+			sample(map[string]string{"namespace": "default", "pod": "web-1"}, "10"),
+			sample(map[string]string{"namespace": "default", "pod": "web-1", "route": "/api"}, "5"),
+			sample(map[string]string{"namespace": "kube-system", "pod": "dns"}, "1"),
+		},
+		"gpu": {
+			sample(map[string]string{"Hostname": "node-1"}, "0.5"),
+			sample(map[string]string{"Hostname": "node-1"}, "0.7"),
+		},
+	})
+
+	index := NewCustomMetricIndex()
+	c := NewCustomMetricsCollector(promClient, CollectorConfig{
+		CustomMetrics: []CustomMetric{
+			{Name: "requests_per_second", Query: "requests"},
+			{Name: "gpu_utilization", Query: "gpu", Resource: CustomResourceNode, Labels: map[string]string{"node": "Hostname"}, Aggregator: AggregatorMax},
+		},
+	}, nil, index)
+
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, result, "the values are attached to the records of the other collectors")
+
+	resources := []ResourceMetrics{
+		{Kind: "Node", Name: "node-1"},
+		{Kind: "Pod", Namespace: "default", Name: "web-1"},
+		{Kind: "Pod", Namespace: "default", Name: "web-2"},
+	}
+	index.Attach(resources)
+	assert.Equal(t, map[string]float64{"gpu_utilization": 0.7}, resources[0].CustomMetrics)
+	assert.Equal(t, map[string]float64{"requests_per_second": 15}, resources[1].CustomMetrics)
+	assert.Nil(t, resources[2].CustomMetrics)
+}
+
+func TestCustomMetricsCollector_PartialFailure(t *testing.T) {
+	promClient, _ := newCustomPrometheus(t, map[string][]map[string]interface{}{
+		"up": {sample(map[string]string{"namespace": "default"}, "1")},
+	})
+
+	index := NewCustomMetricIndex()
+	c := NewCustomMetricsCollector(promClient, CollectorConfig{
+		CustomMetrics: []CustomMetric{{Name: "up", Query: "up"}, {Name: "broken", Query: "sum("}},
+	}, nil, index)
+	_, err := c.Collect(context.Background())
+	assert.True(t, IsDegraded(err), "one failing query does not fail the collection")
+	assert.ErrorContains(t, err, "custom metric broken")
+
+	resources := []ResourceMetrics{{Kind: "Namespace", Name: "default"}}
+	index.Attach(resources)
+	assert.Equal(t, map[string]float64{"up": 1}, resources[0].CustomMetrics)
+
+	c = NewCustomMetricsCollector(promClient, CollectorConfig{
+		CustomMetrics: []CustomMetric{{Name: "broken", Query: "sum("}},
+	}, nil, nil)
+	_, err = c.Collect(context.Background())
+	assert.Error(t, err)
+	assert.False(t, IsDegraded(err))
+}
+
+func TestCustomMetricsCollector_Interval(t *testing.T) {
+	promClient, queries := newCustomPrometheus(t, map[string][]map[string]interface{}{"up": {}})
+
+	runs := NewRunTracker()
+	config := CollectorConfig{CustomMetrics: []CustomMetric{{Name: "up", Query: "up", Interval: time.Hour}}}
+	for i := 0; i < 3; i++ {
+		// Collectors are recreated on every reconcile, the tracker is not
+		_, err := NewCustomMetricsCollector(promClient, config, runs, nil).Collect(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), queries.Load())
+}
+
+func TestCustomMetricsCollector_RequiresPrometheus(t *testing.T) {
+	c := NewCustomMetricsCollector(nil, CollectorConfig{}, nil, nil)
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+
+	c = NewCustomMetricsCollector(nil, CollectorConfig{CustomMetrics: []CustomMetric{{Name: "up", Query: "up"}}}, nil, nil)
+	_, err = c.Collect(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCustomMetricIndex(t *testing.T) {
+	index := NewCustomMetricIndex()
+	index.Publish("requests", map[customResource]float64{{CustomResourcePod, "default", "web-1"}: 10})
+	index.Publish("queue_depth", map[customResource]float64{{CustomResourceNamespace, "", "default"}: 3})
+
+	// The values are set on the records of the other collectors
+	inner := &staticCollector{metrics: []ResourceMetrics{
+		{Kind: "Pod", Namespace: "default", Name: "web-1", CustomMetrics: map[string]float64{"own": 1}},
+		{Kind: "Namespace", Name: "default"},
+	}}
+	metrics, err := WithCustomMetrics(inner, index).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"own": 1, "requests": 10}, metrics[0].CustomMetrics)
+	assert.Equal(t, map[string]float64{"queue_depth": 3}, metrics[1].CustomMetrics)
+	assert.Equal(t, map[string]float64{"own": 1}, inner.metrics[0].CustomMetrics, "the records of the wrapped collector are not changed")
+	assert.Nil(t, inner.metrics[1].CustomMetrics)
+
+	// Values of removed metrics are no longer attached
+	index.Retain([]CustomMetric{{Name: "requests"}})
+	metrics, err = WithCustomMetrics(&staticCollector{metrics: []ResourceMetrics{{Kind: "Namespace", Name: "default"}}}, index).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, metrics[0].CustomMetrics)
+}
+
+func TestCustomMetric_ResourceOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric CustomMetric
+		labels model.Metric
+		want   customResource
+		ok     bool
+	}{
+		{"pod", CustomMetric{}, model.Metric{"namespace": "default", "pod": "web-1"}, customResource{CustomResourcePod, "default", "web-1"}, true},
+		{"pod without namespace", CustomMetric{}, model.Metric{"pod": "web-1"}, customResource{}, false},
+		{"workload kind", CustomMetric{}, model.Metric{"namespace": "default", "workload": "web", "workload_kind": "StatefulSet"}, customResource{"StatefulSet", "default", "web"}, true},
+		{"node", CustomMetric{}, model.Metric{"node": "node-1"}, customResource{CustomResourceNode, "", "node-1"}, true},
+		{"namespace", CustomMetric{}, model.Metric{"namespace": "default"}, customResource{CustomResourceNamespace, "", "default"}, true},
+		{"no resource labels", CustomMetric{}, model.Metric{"job": "api"}, customResource{}, false},
+		{"explicit resource", CustomMetric{Resource: CustomResourceNamespace}, model.Metric{"namespace": "default", "pod": "web-1"}, customResource{CustomResourceNamespace, "", "default"}, true},
+		{"workload kind case", CustomMetric{}, model.Metric{"namespace": "default", "workload": "web", "workload_kind": "daemonset"}, customResource{"DaemonSet", "default", "web"}, true},
+		{"mapped labels", CustomMetric{Labels: map[string]string{"namespace": "exported_namespace", "workload": "deployment"}}, model.Metric{"exported_namespace": "shop", "deployment": "cart"}, customResource{"Deployment", "shop", "cart"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.metric.resourceOf(tt.labels)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	values := []float64{4, 1, 7}
+	assert.Equal(t, 12.0, combine("", values))
+	assert.Equal(t, 12.0, combine(AggregatorSum, values))
+	assert.Equal(t, 4.0, combine(AggregatorAvg, values))
+	assert.Equal(t, 1.0, combine(AggregatorMin, values))
+	assert.Equal(t, 7.0, combine(AggregatorMax, values))
+	assert.Equal(t, 3.0, combine(AggregatorCount, values))
+}
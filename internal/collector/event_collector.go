@@ -5,21 +5,19 @@ import (
 	"fmt"
 	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 // EventCollector collects Kubernetes events
 type EventCollector struct {
-	kubeClient kubernetes.Interface
-	config     CollectorConfig
+	cache  *Cache
+	config CollectorConfig
 }
 
 // NewEventCollector creates a new event collector
-func NewEventCollector(kubeClient kubernetes.Interface, config CollectorConfig) *EventCollector {
+func NewEventCollector(cache *Cache, config CollectorConfig) *EventCollector {
 	return &EventCollector{
-		kubeClient: kubeClient,
-		config:     config,
+		cache:  cache,
+		config: config,
 	}
 }
 
@@ -35,8 +33,8 @@ func (c *EventCollector) Description() string {
 
 // Collect gathers events from the Kubernetes cluster
 func (c *EventCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
-	// List events from all namespaces
-	events, err := c.kubeClient.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+	// List events from the watched namespaces
+	events, err := c.cache.Events(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list events: %w", err)
 	}
@@ -44,7 +42,7 @@ func (c *EventCollector) Collect(ctx context.Context) ([]ResourceMetrics, error)
 	var metrics []ResourceMetrics
 
 	// Process each event
-	for _, event := range events.Items {
+	for _, event := range events {
 		// Skip events in excluded namespaces
 		if contains(c.config.ExcludeNamespaces, event.Namespace) {
 			continue
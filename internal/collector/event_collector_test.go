@@ -118,7 +118,9 @@ func TestEventCollector_Collect(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create the event collector with the test config
-			collector := NewEventCollector(client, tc.config)
+			cache := NewCache(client, nil)
+			defer cache.Stop()
+			collector := NewEventCollector(cache, tc.config)
 
 			// Collect events
 			metrics, err := collector.Collect(context.Background())
@@ -4,20 +4,17 @@ import (
 	"context"
 	"fmt"
 	"time"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type IngressCollector struct {
-	kubeClient kubernetes.Interface
-	config     CollectorConfig
+	cache  *Cache
+	config CollectorConfig
 }
 
-func NewIngressCollector(kubeClient kubernetes.Interface, config CollectorConfig) *IngressCollector {
+func NewIngressCollector(cache *Cache, config CollectorConfig) *IngressCollector {
 	return &IngressCollector{
-		kubeClient: kubeClient,
-		config:     config,
+		cache:  cache,
+		config: config,
 	}
 }
 
@@ -30,12 +27,12 @@ func (ic *IngressCollector) Description() string {
 func (ic *IngressCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 	var metrics []ResourceMetrics
 
-	ingresses, err := ic.kubeClient.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	ingresses, err := ic.cache.Ingresses(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list ingresses: %w", err)
 	}
 
-	for _, ing := range ingresses.Items {
+	for _, ing := range ingresses {
 		if ic.isNamespaceExcluded(ing.Namespace) {
 			continue
 		}
@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CounterTracker remembers the last sample of cumulative counters to turn
+// them into increases between collections. It outlives the collectors, which
+// are recreated on every reconcile.
+type CounterTracker struct {
+	mu      sync.Mutex
+	samples map[string]counterSample
+	seen    map[string]bool
+}
+
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+// NewCounterTracker creates an empty counter tracker
+func NewCounterTracker() *CounterTracker {
+	return &CounterTracker{
+		samples: make(map[string]counterSample),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Increase records a sample of the named counter taken at at and returns the
+// increase since the previous sample and the seconds between both. ok is
+// false for the first sample of a counter, after a counter reset and when
+// the counter was not sampled again since.
+func (t *CounterTracker) Increase(name string, value float64, at time.Time) (increase, seconds float64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen[name] = true
+
+	previous, found := t.samples[name]
+	if found && !at.After(previous.at) {
+		return 0, 0, false
+	}
+	t.samples[name] = counterSample{value: value, at: at}
+	if !found || value < previous.value {
+		return 0, 0, false
+	}
+	return value - previous.value, at.Sub(previous.at).Seconds(), true
+}
+
+// Prune forgets the counters that were not sampled since the previous call,
+// such as those of deleted pods
+func (t *CounterTracker) Prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.samples {
+		if !t.seen[name] {
+			delete(t.samples, name)
+		}
+	}
+	t.seen = make(map[string]bool)
+}
+
+// KubeletCollector reads the stats summary of every node and reports the
+// CPU, memory, filesystem and network usage of each pod and its containers
+type KubeletCollector struct {
+	cache              *Cache
+	kubeletClient      *metrics.KubeletClient
+	config             CollectorConfig
+	maxConcurrentNodes int
+	counters           *CounterTracker
+}
+
+// NewKubeletCollector creates a kubelet collector reading the summaries
+// through kubeClient and querying at most
+// maxConcurrentNodes nodes at once, config.MaxConcurrentCollections when
+// zero. counters may be nil, in which case cumulative counters are only
+// reported from the second collection of the collector on.
+func NewKubeletCollector(kubeClient kubernetes.Interface, cache *Cache, config CollectorConfig, maxConcurrentNodes int, counters *CounterTracker) *KubeletCollector {
+	if maxConcurrentNodes <= 0 {
+		maxConcurrentNodes = config.MaxConcurrentCollections
+	}
+	if maxConcurrentNodes <= 0 {
+		maxConcurrentNodes = 1
+	}
+	if counters == nil {
+		counters = NewCounterTracker()
+	}
+	return &KubeletCollector{
+		cache:              cache,
+		kubeletClient:      metrics.NewKubeletClient(kubeClient),
+		config:             config,
+		maxConcurrentNodes: maxConcurrentNodes,
+		counters:           counters,
+	}
+}
+
+func (c *KubeletCollector) Name() string { return "kubelet-collector" }
+
+func (c *KubeletCollector) Description() string {
+	return "Collects pod and container stats from the kubelet stats summary of each node"
+}
+
+func (c *KubeletCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
+	nodes, err := c.cache.Nodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	summaries := make([]*metrics.Summary, len(nodes))
+	errs := make([]error, len(nodes))
+	slots := make(chan struct{}, c.maxConcurrentNodes)
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			summaries[i], errs[i] = c.kubeletClient.GetSummary(ctx, name)
+		}(i, node.Name)
+	}
+	wg.Wait()
+
+	// Fail only when no node answered, so one unreachable kubelet does not
+	// hide the others
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 && len(failed) == len(nodes) {
+		return nil, errors.Join(failed...)
+	}
+	var degraded error
+	if len(failed) > 0 {
+		degraded = &DegradedError{Err: fmt.Errorf("failed to read the stats summary of %d of %d nodes: %w",
+			len(failed), len(nodes), errors.Join(failed...))}
+	}
+
+	now := time.Now()
+	var result []ResourceMetrics
+	for i, summary := range summaries {
+		if summary == nil {
+			continue
+		}
+		for _, pod := range summary.Pods {
+			if !c.collectsNamespace(pod.PodRef.Namespace) {
+				continue
+			}
+			result = append(result, c.podMetrics(nodes[i].Name, pod, now))
+		}
+	}
+	// The counters of pods on unreachable nodes are kept until their node
+	// answers again, so their traffic is not lost in between
+	if len(failed) == 0 {
+		c.counters.Prune()
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, degraded
+}
+
+func (c *KubeletCollector) collectsNamespace(namespace string) bool {
+	if contains(c.config.ExcludeNamespaces, namespace) {
+		return false
+	}
+	return len(c.config.IncludeNamespaces) == 0 || contains(c.config.IncludeNamespaces, namespace)
+}
+
+// podMetrics converts the stats of a pod into a PodStats record, which the
+// Pod record of the pod collector for the same pod does not collide with.
+// Counters are keyed by pod and container so their increases survive pods
+// moving between summaries.
+func (c *KubeletCollector) podMetrics(node string, pod metrics.PodStats, now time.Time) ResourceMetrics {
+	key := pod.PodRef.Namespace + "/" + pod.PodRef.Name
+	result := ResourceMetrics{
+		Name:        pod.PodRef.Name,
+		Namespace:   pod.PodRef.Namespace,
+		Kind:        "PodStats",
+		CollectedAt: now,
+		CPU:         cpuStatsMetrics(pod.CPU),
+		Memory:      c.memoryStatsMetrics(key, pod.Memory),
+		Network:     c.networkStatsMetrics(key, pod.Network),
+		Status: map[string]interface{}{
+			"node":   node,
+			"source": SourceKubelet,
+		},
+	}
+	if pod.EphemeralStorage != nil {
+		result.Storage = fsStorageMetrics(pod.EphemeralStorage)
+	}
+
+	for _, container := range pod.Containers {
+		metric := ContainerMetrics{
+			Name:   container.Name,
+			CPU:    cpuStatsMetrics(container.CPU),
+			Memory: c.memoryStatsMetrics(key+"/"+container.Name, container.Memory),
+			Source: SourceKubelet,
+		}
+		if container.Rootfs != nil {
+			rootfs := fsStorageMetrics(container.Rootfs)
+			metric.Rootfs = &rootfs
+		}
+		if container.Logs != nil {
+			logs := fsStorageMetrics(container.Logs)
+			metric.Logs = &logs
+		}
+		result.Containers = append(result.Containers, metric)
+	}
+	return result
+}
+
+func cpuStatsMetrics(stats *metrics.CPUStats) CPUMetrics {
+	if stats == nil {
+		return CPUMetrics{}
+	}
+	nanoCores := metrics.Value(stats.UsageNanoCores)
+	return CPUMetrics{
+		UsageNanoCores:   int64(nanoCores),
+		UsageCorePercent: nanoCores / 1e9 * 100,
+	}
+}
+
+// memoryStatsMetrics reports the working set as usage, like the Metrics
+// Server, and the page faults since the previous collection
+func (c *KubeletCollector) memoryStatsMetrics(key string, stats *metrics.MemoryStats) MemoryMetrics {
+	if stats == nil {
+		return MemoryMetrics{}
+	}
+	result := MemoryMetrics{
+		UsageBytes: int64(metrics.Value(stats.WorkingSetBytes)),
+		RSSBytes:   int64(metrics.Value(stats.RSSBytes)),
+	}
+	if stats.PageFaults != nil {
+		increase, _, _ := c.counters.Increase(key+"/pageFaults", metrics.Value(stats.PageFaults), stats.Time)
+		result.PageFaults = int64(increase)
+	}
+	if stats.MajorPageFaults != nil {
+		increase, _, _ := c.counters.Increase(key+"/majorPageFaults", metrics.Value(stats.MajorPageFaults), stats.Time)
+		result.MajorPageFaults = int64(increase)
+	}
+	return result
+}
+
+// networkStatsMetrics reports the traffic since the previous collection and
+// its bandwidth. The kubelet reports no packet counts.
+func (c *KubeletCollector) networkStatsMetrics(key string, stats *metrics.NetworkStats) NetworkMetrics {
+	var result NetworkMetrics
+	if stats == nil {
+		return result
+	}
+	counter := func(name string, value *uint64) (float64, float64) {
+		if value == nil {
+			return 0, 0
+		}
+		increase, seconds, _ := c.counters.Increase(key+"/"+name, metrics.Value(value), stats.Time)
+		return increase, seconds
+	}
+
+	rxBytes, rxSeconds := counter("rxBytes", stats.RxBytes)
+	txBytes, txSeconds := counter("txBytes", stats.TxBytes)
+	rxErrors, _ := counter("rxErrors", stats.RxErrors)
+	txErrors, _ := counter("txErrors", stats.TxErrors)
+	result.RxBytes = int64(rxBytes)
+	result.TxBytes = int64(txBytes)
+	result.RxErrors = int64(rxErrors)
+	result.TxErrors = int64(txErrors)
+	if rxSeconds > 0 {
+		result.RxBytesPerSecond = rxBytes / rxSeconds
+	}
+	if txSeconds > 0 {
+		result.TxBytesPerSecond = txBytes / txSeconds
+	}
+	return result
+}
+
+func fsStorageMetrics(stats *metrics.FsStats) StorageMetrics {
+	return StorageMetrics{
+		UsageBytes:    int64(metrics.Value(stats.UsedBytes)),
+		CapacityBytes: int64(metrics.Value(stats.CapacityBytes)),
+		Available:     int64(metrics.Value(stats.AvailableBytes)),
+		Inodes:        int64(metrics.Value(stats.Inodes)),
+		InodesUsed:    int64(metrics.Value(stats.InodesUsed)),
+		InodesFree:    int64(metrics.Value(stats.InodesFree)),
+	}
+}
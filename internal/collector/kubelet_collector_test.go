@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// kubeletSummary is the stats summary of node-1 at the given sample time,
+// with counters growing by 1000 per second
+func kubeletSummary(at time.Time, seconds int) string {
+	return fmt.Sprintf(`{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "web-1", "namespace": "default"},
+      "cpu": {"time": %[1]q, "usageNanoCores": 250000000},
+      "memory": {"time": %[1]q, "workingSetBytes": 104857600, "rssBytes": 83886080, "pageFaults": %[2]d, "majorPageFaults": 3},
+      "network": {"time": %[1]q, "rxBytes": %[2]d, "txBytes": %[3]d, "rxErrors": 1, "txErrors": 0},
+      "ephemeral-storage": {"usedBytes": 4096, "capacityBytes": 10737418240, "availableBytes": 5368709120},
+      "containers": [
+        {
+          "name": "app",
+          "cpu": {"time": %[1]q, "usageNanoCores": 200000000},
+          "memory": {"time": %[1]q, "workingSetBytes": 94371840, "rssBytes": 73400320, "pageFaults": %[2]d},
+          "rootfs": {"usedBytes": 2048, "inodesUsed": 12},
+          "logs": {"usedBytes": 1024}
+        }
+      ]
+    },
+    {"podRef": {"name": "coredns", "namespace": "kube-system"}}
+  ]
+}`, at.Format(time.RFC3339), 1000*seconds, 2000*seconds)
+}
+
+// newKubeletServer serves the summaries of node-1 and node-2 through a fake
+// API server node proxy. node-2 fails. Each summary of node-1 is sampled 30
+// seconds after the previous one.
+func newKubeletServer(t *testing.T) kubernetes.Interface {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/nodes/node-1/proxy/stats/summary":
+			seconds := 30 * int(samples.Add(1))
+			fmt.Fprint(w, kubeletSummary(start.Add(time.Duration(seconds)*time.Second), seconds))
+		default:
+			http.Error(w, "kubelet unreachable", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	return kubeClient
+}
+
+func testNode(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestKubeletCollector(t *testing.T) {
+	config := CollectorConfig{ExcludeNamespaces: []string{"kube-system"}}
+	nodes := newTestCache(t, testNode("node-1"), testNode("node-2"))
+	c := NewKubeletCollector(newKubeletServer(t), nodes, config, 2, nil)
+
+	result, err := c.Collect(context.Background())
+	assert.True(t, IsDegraded(err), "an unreachable node does not fail the collection")
+	assert.ErrorContains(t, err, "node-2")
+	assert.Len(t, result, 1)
+
+	pod := result[0]
+	assert.Equal(t, "web-1", pod.Name)
+	assert.Equal(t, "PodStats", pod.Kind, "the pod collector reports the Pod records")
+	assert.Equal(t, SourceKubelet, pod.Status["source"])
+	assert.Equal(t, "node-1", pod.Status["node"])
+	assert.Equal(t, int64(250000000), pod.CPU.UsageNanoCores)
+	assert.InDelta(t, 25.0, pod.CPU.UsageCorePercent, 0.001)
+	assert.Equal(t, int64(104857600), pod.Memory.UsageBytes)
+	assert.Equal(t, int64(4096), pod.Storage.UsageBytes)
+	assert.Equal(t, int64(10737418240), pod.Storage.CapacityBytes)
+	assert.Zero(t, pod.Network.RxBytes, "counters need a previous sample")
+
+	assert.Len(t, pod.Containers, 1)
+	container := pod.Containers[0]
+	assert.Equal(t, "app", container.Name)
+	assert.Equal(t, int64(200000000), container.CPU.UsageNanoCores)
+	assert.Equal(t, int64(73400320), container.Memory.RSSBytes)
+	assert.Equal(t, int64(2048), container.Rootfs.UsageBytes)
+	assert.Equal(t, int64(12), container.Rootfs.InodesUsed)
+	assert.Equal(t, int64(1024), container.Logs.UsageBytes)
+
+	// The second collection reports the increase over the 30s in between
+	result, err = c.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+	pod = result[0]
+	assert.Equal(t, int64(30000), pod.Network.RxBytes)
+	assert.Equal(t, int64(60000), pod.Network.TxBytes)
+	assert.Zero(t, pod.Network.RxErrors)
+	assert.InDelta(t, 1000.0, pod.Network.RxBytesPerSecond, 0.001)
+	assert.InDelta(t, 2000.0, pod.Network.TxBytesPerSecond, 0.001)
+	assert.Equal(t, int64(30000), pod.Memory.PageFaults)
+	assert.Zero(t, pod.Memory.MajorPageFaults)
+	assert.Equal(t, int64(30000), pod.Containers[0].Memory.PageFaults)
+}
+
+func TestKubeletCollector_AllNodesFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "kubelet unreachable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	_, err = NewKubeletCollector(kubeClient, newTestCache(t, testNode("node-2")), CollectorConfig{}, 0, nil).Collect(context.Background())
+	assert.Error(t, err)
+}
+
+func TestKubeletCollector_KeepsCountersOfFailedNodes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/nodes/node-1/proxy/stats/summary":
+			// The second summary fails
+			sample := int(samples.Add(1))
+			if sample == 2 {
+				http.Error(w, "kubelet unreachable", http.StatusServiceUnavailable)
+				return
+			}
+			seconds := 30 * sample
+			fmt.Fprint(w, kubeletSummary(start.Add(time.Duration(seconds)*time.Second), seconds))
+		default:
+			fmt.Fprint(w, `{"pods":[]}`)
+		}
+	}))
+	defer server.Close()
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	c := NewKubeletCollector(kubeClient, newTestCache(t, testNode("node-1"), testNode("node-2")), CollectorConfig{}, 1, nil)
+
+	_, err = c.Collect(context.Background())
+	assert.NoError(t, err)
+	_, err = c.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+
+	// The traffic while node-1 was unreachable is reported once it answers
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "web-1", result[0].Name)
+	assert.Equal(t, int64(60000), result[0].Network.RxBytes)
+	assert.InDelta(t, 1000.0, result[0].Network.RxBytesPerSecond, 0.001)
+}
+
+func TestCounterTracker(t *testing.T) {
+	tracker := NewCounterTracker()
+	start := time.Now()
+
+	_, _, ok := tracker.Increase("rx", 100, start)
+	assert.False(t, ok, "first sample")
+
+	increase, seconds, ok := tracker.Increase("rx", 400, start.Add(10*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 300.0, increase)
+	assert.Equal(t, 10.0, seconds)
+
+	_, _, ok = tracker.Increase("rx", 400, start.Add(10*time.Second))
+	assert.False(t, ok, "sample not refreshed")
+
+	_, _, ok = tracker.Increase("rx", 50, start.Add(20*time.Second))
+	assert.False(t, ok, "counter reset")
+
+	// A counter missing from a collection is forgotten
+	tracker.Prune()
+	tracker.Prune()
+	_, _, ok = tracker.Increase("rx", 80, start.Add(30*time.Second))
+	assert.False(t, ok)
+}
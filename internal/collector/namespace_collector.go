@@ -4,20 +4,17 @@ import (
 	"context"
 	"fmt"
 	"time"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type NamespaceCollector struct {
-	kubeClient kubernetes.Interface
-	config     CollectorConfig
+	cache  *Cache
+	config CollectorConfig
 }
 
-func NewNamespaceCollector(kubeClient kubernetes.Interface, config CollectorConfig) *NamespaceCollector {
+func NewNamespaceCollector(cache *Cache, config CollectorConfig) *NamespaceCollector {
 	return &NamespaceCollector{
-		kubeClient: kubeClient,
-		config:     config,
+		cache:  cache,
+		config: config,
 	}
 }
 
@@ -30,12 +27,12 @@ func (nc *NamespaceCollector) Description() string {
 func (nc *NamespaceCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 	var metrics []ResourceMetrics
 
-	namespaces, err := nc.kubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	namespaces, err := nc.cache.Namespaces(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list namespaces: %w", err)
 	}
 
-	for _, ns := range namespaces.Items {
+	for _, ns := range namespaces {
 		if nc.isNamespaceExcluded(ns.Name) {
 			continue
 		}
@@ -2,19 +2,19 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/hakongo/kubernetes-connector/internal/metrics"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 type NodeCollector struct {
-	kubeClient       kubernetes.Interface
+	cache            *Cache
 	metricsClient    versioned.Interface
 	prometheusClient *metrics.PrometheusClient
 	config           CollectorConfig
@@ -22,9 +22,9 @@ type NodeCollector struct {
 	useMetricsServer bool
 }
 
-func NewNodeCollector(kubeClient kubernetes.Interface, metricsClient versioned.Interface, prometheusClient *metrics.PrometheusClient, config CollectorConfig, usePrometheus bool, useMetricsServer bool) *NodeCollector {
+func NewNodeCollector(cache *Cache, metricsClient versioned.Interface, prometheusClient *metrics.PrometheusClient, config CollectorConfig, usePrometheus bool, useMetricsServer bool) *NodeCollector {
 	return &NodeCollector{
-		kubeClient:       kubeClient,
+		cache:            cache,
 		metricsClient:    metricsClient,
 		prometheusClient: prometheusClient,
 		config:           config,
@@ -42,33 +42,36 @@ func (nc *NodeCollector) Description() string {
 func (nc *NodeCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 	var metrics []ResourceMetrics
 
-	nodes, err := nc.kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	nodes, err := nc.cache.Nodes(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list nodes: %w", err)
 	}
 
+	// The nodes are still reported when their usage cannot be read
+	var unavailable []error
+
 	// Get metrics based on user configuration
 	var nodePrometheusMetrics map[string]*PrometheusNodeMetrics
 	var nodeK8sMetricsMap map[string]*metricsv1beta1.NodeMetrics
 
 	// Get Prometheus metrics if configured
 	if nc.usePrometheus && nc.prometheusClient != nil {
-		nodePrometheusMetrics = make(map[string]*PrometheusNodeMetrics)
-
-		// Get metrics for each node from Prometheus
-		for _, node := range nodes.Items {
-			promNodeMetric, err := nc.prometheusClient.GetNodeMetrics(ctx, node.Name)
-			if err != nil {
-				// Log error but continue with other nodes
-				fmt.Printf("Warning: failed to get Prometheus metrics for node %s: %v\n", node.Name, err)
-				continue
-			}
-			// Convert from Prometheus client NodeMetrics to our internal PrometheusNodeMetrics
-			nodeMetric := &PrometheusNodeMetrics{
-				CPUUsage:    promNodeMetric.CPUUsage,
-				MemoryUsage: promNodeMetric.MemoryUsage,
+		// Fetch the usage of all nodes at once
+		promNodeMetrics, err := nc.prometheusClient.GetClusterNodeMetrics(ctx, nc.config.CollectionInterval)
+		if err != nil {
+			unavailable = append(unavailable, fmt.Errorf("failed to get node metrics from Prometheus: %w", err))
+		} else {
+			nodePrometheusMetrics = make(map[string]*PrometheusNodeMetrics, len(promNodeMetrics))
+			for name, promNodeMetric := range promNodeMetrics {
+				// Convert from Prometheus client NodeMetrics to our internal PrometheusNodeMetrics
+				nodePrometheusMetrics[name] = &PrometheusNodeMetrics{
+					CPUUsage:    promNodeMetric.CPUUsage,
+					MemoryUsage: promNodeMetric.MemoryUsage,
+					CPUStats:    promNodeMetric.CPUStats,
+					MemoryStats: promNodeMetric.MemoryStats,
+					Network:     promNodeMetric.Network,
+				}
 			}
-			nodePrometheusMetrics[node.Name] = nodeMetric
 		}
 	}
 
@@ -76,8 +79,7 @@ func (nc *NodeCollector) Collect(ctx context.Context) ([]ResourceMetrics, error)
 	if nc.useMetricsServer {
 		nodeMetrics, err := nc.metricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 		if err != nil {
-			// Log error but continue
-			fmt.Printf("Warning: failed to get node metrics from Kubernetes metrics API: %v\n", err)
+			unavailable = append(unavailable, fmt.Errorf("failed to list node metrics from the Metrics Server: %w", err))
 		} else {
 			nodeK8sMetricsMap = make(map[string]*metricsv1beta1.NodeMetrics)
 			for _, nodeMetric := range nodeMetrics.Items {
@@ -86,7 +88,7 @@ func (nc *NodeCollector) Collect(ctx context.Context) ([]ResourceMetrics, error)
 		}
 	}
 
-	for _, node := range nodes.Items {
+	for _, node := range nodes {
 		metric := ResourceMetrics{
 			Name:        node.Name,
 			Kind:        "Node",
@@ -100,6 +102,7 @@ func (nc *NodeCollector) Collect(ctx context.Context) ([]ResourceMetrics, error)
 			if promMetric, exists := nodePrometheusMetrics[node.Name]; exists {
 				metric.CPU = nc.calculateCPUMetricsFromPrometheus(promMetric)
 				metric.Memory = nc.calculateMemoryMetricsFromPrometheus(promMetric)
+				metric.Network = newNetworkMetrics(promMetric.Network, nc.config.CollectionInterval)
 			}
 		}
 
@@ -118,13 +121,15 @@ func (nc *NodeCollector) Collect(ctx context.Context) ([]ResourceMetrics, error)
 		}
 
 		// These metrics don't depend on Prometheus or Kubernetes metrics API
-		metric.Storage = nc.calculateStorageMetrics(&node)
-		metric.Network = nc.calculateNetworkMetrics(&node)
-		metric.Cost = nc.calculateCostMetrics(metric.CPU, metric.Memory, &node)
+		metric.Storage = nc.calculateStorageMetrics(node)
+		metric.Cost = nc.calculateCostMetrics(metric.CPU, metric.Memory, metric.Network, node)
 
 		metrics = append(metrics, metric)
 	}
 
+	if len(unavailable) > 0 {
+		return metrics, &DegradedError{Err: errors.Join(unavailable...)}
+	}
 	return metrics, nil
 }
 
@@ -141,12 +146,19 @@ func (nc *NodeCollector) calculateCPUMetrics(metrics *metricsv1beta1.NodeMetrics
 type PrometheusNodeMetrics struct {
 	CPUUsage    float64 // CPU usage in cores
 	MemoryUsage float64 // Memory usage in bytes
+
+	// Statistics over the collection interval
+	CPUStats    metrics.UsageStats
+	MemoryStats metrics.UsageStats
+
+	Network metrics.NetworkRates // Network traffic per second
 }
 
 func (nc *NodeCollector) calculateCPUMetricsFromPrometheus(metrics *PrometheusNodeMetrics) CPUMetrics {
 	var cpu CPUMetrics
 	cpu.UsageNanoCores = int64(metrics.CPUUsage * 1e9) // Convert cores to nanocores
 	cpu.UsageCorePercent = metrics.CPUUsage
+	cpu.setStats(metrics.CPUStats)
 	return cpu
 }
 
@@ -157,9 +169,11 @@ func (nc *NodeCollector) calculateMemoryMetrics(metrics *metricsv1beta1.NodeMetr
 }
 
 func (nc *NodeCollector) calculateMemoryMetricsFromPrometheus(metrics *PrometheusNodeMetrics) MemoryMetrics {
-	return MemoryMetrics{
+	memory := MemoryMetrics{
 		UsageBytes: int64(metrics.MemoryUsage),
 	}
+	memory.setStats(metrics.MemoryStats)
+	return memory
 }
 
 func (nc *NodeCollector) calculateStorageMetrics(node *corev1.Node) StorageMetrics {
@@ -173,35 +187,32 @@ func (nc *NodeCollector) calculateStorageMetrics(node *corev1.Node) StorageMetri
 	return storage
 }
 
-func (nc *NodeCollector) calculateNetworkMetrics(node *corev1.Node) NetworkMetrics {
-	var net NetworkMetrics
-	// Get network metrics from node conditions and status
-	for _, address := range node.Status.Addresses {
-		if address.Type == corev1.NodeInternalIP {
-			// Node has internal network connectivity
-			net.TxPackets++ // Increment to indicate network is available
-			break
-		}
-	}
-	return net
-}
-
-func (nc *NodeCollector) calculateCostMetrics(cpu CPUMetrics, memory MemoryMetrics, node *corev1.Node) CostMetrics {
+func (nc *NodeCollector) calculateCostMetrics(cpu CPUMetrics, memory MemoryMetrics, network NetworkMetrics, node *corev1.Node) CostMetrics {
 	// Calculate cost based on node size, usage, and instance type
 	var allocatable = node.Status.Allocatable
 	cpuCores := float64(allocatable.Cpu().Value()) / 1e9
 	memoryGB := float64(allocatable.Memory().Value()) / float64(1<<30)
 
 	// Base cost calculation
-	cpuCost := cpuCores * 0.04    // $0.04 per core hour
-	memoryCost := memoryGB * 0.01 // $0.01 per GB hour
-
-	// Adjust cost based on actual usage
-	if cpu.UsageCorePercent > 0 {
-		cpuCost *= cpu.UsageCorePercent
+	rates := nc.config.CostRates.WithDefaults()
+	cpuCost := cpuCores * rates.CPUPerCoreHour
+	memoryCost := memoryGB * rates.MemoryPerGBHour
+
+	// Adjust cost based on actual usage, averaged over the collection
+	// interval when available so that spikes between samples are billed
+	cpuUsage := cpu.UsageCorePercent
+	if cpu.UsageAvgNanoCores > 0 {
+		cpuUsage = float64(cpu.UsageAvgNanoCores) / 1e9
+	}
+	if cpuUsage > 0 {
+		cpuCost *= cpuUsage
 	}
-	if memory.UsageBytes > 0 {
-		usageGB := float64(memory.UsageBytes) / float64(1<<30)
+	memoryUsage := memory.UsageBytes
+	if memory.UsageAvgBytes > 0 {
+		memoryUsage = memory.UsageAvgBytes
+	}
+	if memoryUsage > 0 {
+		usageGB := float64(memoryUsage) / float64(1<<30)
 		memoryCost *= (usageGB / memoryGB)
 	}
 
@@ -212,10 +223,17 @@ func (nc *NodeCollector) calculateCostMetrics(cpu CPUMetrics, memory MemoryMetri
 		// This would require a pricing table for different instance types
 	}
 
+	// All transmitted traffic at the average bandwidth of the interval for
+	// one hour. It includes traffic that stays in the cluster, so it is an
+	// upper bound of the egress cost and left out of the total until egress
+	// can be told apart.
+	networkCost := network.TxBytesPerSecond * 3600 / float64(1<<30) * rates.NetworkPerGB
+
 	return CostMetrics{
-		Currency:   "USD",
-		CPUCost:    cpuCost,
-		MemoryCost: memoryCost,
-		TotalCost:  cpuCost + memoryCost,
+		Currency:    rates.Currency,
+		CPUCost:     cpuCost,
+		MemoryCost:  memoryCost,
+		NetworkCost: networkCost,
+		TotalCost:   cpuCost + memoryCost,
 	}
 }
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// maxOwnerDepth bounds the owner chain followed for a pod
+const maxOwnerDepth = 10
+
+// Owner is the top-level controller of a pod
+type Owner struct {
+	APIVersion string
+	Kind       string
+	Name       string
+}
+
+// ownerKey identifies an owner within the namespace of its pods
+type ownerKey struct {
+	namespace  string
+	apiVersion string
+	kind       string
+	name       string
+}
+
+// OwnerResolver resolves pods to the top-level controller of their owner
+// chain, such as the Deployment of a ReplicaSet or the CronJob of a Job.
+// Owners the cache holds are read from it. Other owners, such as Argo
+// Rollouts, are read through the dynamic client.
+type OwnerResolver struct {
+	cache         *Cache
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+}
+
+// NewOwnerResolver creates an owner resolver. dynamicClient and mapper may be
+// nil, in which case the chain stops at the first owner the cache does not
+// hold.
+func NewOwnerResolver(cache *Cache, dynamicClient dynamic.Interface, mapper meta.RESTMapper) *OwnerResolver {
+	return &OwnerResolver{
+		cache:         cache,
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+	}
+}
+
+// PodOwners returns the top-level controller of each pod that has one. When
+// an owner cannot be read, the chain stops at the last owner reference
+// followed. Owners shared by several pods are only read once, so the error
+// joins one error per owner that could not be read.
+func (r *OwnerResolver) PodOwners(ctx context.Context, pods []*corev1.Pod) (map[metrics.PodKey]Owner, error) {
+	resolved := make(map[ownerKey]Owner)
+	result := make(map[metrics.PodKey]Owner)
+	var errs []error
+	for _, pod := range pods {
+		ref := metav1.GetControllerOfNoCopy(pod)
+		if ref == nil {
+			continue
+		}
+		owner, err := r.resolve(ctx, pod.Namespace, *ref, resolved)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		result[metrics.PodKey{Namespace: pod.Namespace, Name: pod.Name}] = owner
+	}
+	return result, errors.Join(errs...)
+}
+
+// resolve follows the controller references from ref up to the top-level
+// controller and remembers it for every owner on the way. The error of an
+// owner that cannot be read is only returned by the lookup that read it.
+func (r *OwnerResolver) resolve(ctx context.Context, namespace string, ref metav1.OwnerReference, resolved map[ownerKey]Owner) (Owner, error) {
+	var chain []ownerKey
+	var owner Owner
+	var err error
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		key := ownerKey{namespace: namespace, apiVersion: ref.APIVersion, kind: ref.Kind, name: ref.Name}
+		if top, ok := resolved[key]; ok {
+			owner = top
+			break
+		}
+		chain = append(chain, key)
+		owner = Owner{APIVersion: ref.APIVersion, Kind: ref.Kind, Name: ref.Name}
+
+		var parent *metav1.OwnerReference
+		parent, err = r.controllerOf(ctx, namespace, ref)
+		if err != nil || parent == nil {
+			break
+		}
+		ref = *parent
+	}
+	for _, key := range chain {
+		resolved[key] = owner
+	}
+	return owner, err
+}
+
+// controllerOf returns the controller reference of the owner ref points to,
+// nil when it has none or no longer exists
+func (r *OwnerResolver) controllerOf(ctx context.Context, namespace string, ref metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse owner API version %q: %w", ref.APIVersion, err)
+	}
+	kind := gv.WithKind(ref.Kind).GroupKind()
+
+	obj, cached, err := r.cache.Object(ctx, kind, namespace, ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", ref.Kind, namespace, ref.Name, err)
+	}
+	if !cached {
+		if r.dynamicClient == nil || r.mapper == nil {
+			return nil, nil
+		}
+		obj, err = r.getObject(ctx, gv, kind, namespace, ref.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	return metav1.GetControllerOfNoCopy(obj), nil
+}
+
+// getObject reads an owner of a kind the cache does not hold, such as a
+// custom resource, nil when it does not exist
+func (r *OwnerResolver) getObject(ctx context.Context, gv schema.GroupVersion, kind schema.GroupKind, namespace, name string) (metav1.Object, error) {
+	mapping, err := r.mapper.RESTMapping(kind, gv.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map owner kind %s: %w", kind, err)
+	}
+
+	resource := r.dynamicClient.Resource(mapping.Resource)
+	getter := dynamic.ResourceInterface(resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		getter = resource.Namespace(namespace)
+	}
+	obj, err := getter.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, name, err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// controlledBy returns the metadata of an object in default controlled by
+// the owner of the given API version and kind
+func controlledBy(name, apiVersion, kind, owner string) metav1.ObjectMeta {
+	isController := true
+	return metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      name,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Name:       owner,
+			Controller: &isController,
+		}},
+	}
+}
+
+// rolloutMapper maps the Argo Rollout kind
+func rolloutMapper() meta.RESTMapper {
+	gv := schema.GroupVersion{Group: "argoproj.io", Version: "v1alpha1"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gv})
+	mapper.Add(gv.WithKind("Rollout"), meta.RESTScopeNamespace)
+	return mapper
+}
+
+func TestOwnerResolver_PodOwners(t *testing.T) {
+	pods := []*corev1.Pod{
+		{ObjectMeta: controlledBy("web-1", "apps/v1", "ReplicaSet", "web-5d4f")},
+		{ObjectMeta: controlledBy("web-2", "apps/v1", "ReplicaSet", "web-5d4f")},
+		{ObjectMeta: controlledBy("report-1", "batch/v1", "Job", "report-28500")},
+		{ObjectMeta: controlledBy("canary-1", "apps/v1", "ReplicaSet", "canary-7c9b")},
+		{ObjectMeta: controlledBy("db-0", "apps/v1", "StatefulSet", "db")},
+		{ObjectMeta: controlledBy("orphan-1", "apps/v1", "ReplicaSet", "deleted")},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "standalone"}},
+	}
+	cache := newTestCache(t,
+		&appsv1.ReplicaSet{ObjectMeta: controlledBy("web-5d4f", "apps/v1", "Deployment", "web")},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+		&batchv1.Job{ObjectMeta: controlledBy("report-28500", "batch/v1", "CronJob", "report")},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "report"}},
+		&appsv1.ReplicaSet{ObjectMeta: controlledBy("canary-7c9b", "argoproj.io/v1alpha1", "Rollout", "canary")},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"}},
+	)
+	rollout := &unstructured.Unstructured{}
+	rollout.SetAPIVersion("argoproj.io/v1alpha1")
+	rollout.SetKind("Rollout")
+	rollout.SetNamespace("default")
+	rollout.SetName("canary")
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), rollout)
+
+	owners, err := NewOwnerResolver(cache, dynamicClient, rolloutMapper()).PodOwners(context.Background(), pods)
+	assert.NoError(t, err)
+	owner := func(name string) Owner {
+		return owners[metrics.PodKey{Namespace: "default", Name: name}]
+	}
+	assert.Equal(t, Owner{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}, owner("web-1"))
+	assert.Equal(t, owner("web-1"), owner("web-2"))
+	assert.Equal(t, Owner{APIVersion: "batch/v1", Kind: "CronJob", Name: "report"}, owner("report-1"))
+	assert.Equal(t, Owner{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "canary"}, owner("canary-1"))
+	assert.Len(t, dynamicClient.Actions(), 1, "only the rollout is read through the dynamic client")
+	assert.Equal(t, "rollouts", dynamicClient.Actions()[0].GetResource().Resource)
+	assert.Equal(t, Owner{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db"}, owner("db-0"))
+	assert.Equal(t, Owner{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "deleted"}, owner("orphan-1"), "the chain stops at a missing owner")
+	assert.NotContains(t, owners, metrics.PodKey{Namespace: "default", Name: "standalone"})
+}
+
+func TestOwnerResolver_NoDynamicClient(t *testing.T) {
+	pods := []*corev1.Pod{{ObjectMeta: controlledBy("canary-1", "apps/v1", "ReplicaSet", "canary-7c9b")}}
+	cache := newTestCache(t, &appsv1.ReplicaSet{ObjectMeta: controlledBy("canary-7c9b", "argoproj.io/v1alpha1", "Rollout", "canary")})
+
+	// Custom resource owners are reported without reading them
+	owners, err := NewOwnerResolver(cache, nil, nil).PodOwners(context.Background(), pods)
+	assert.NoError(t, err)
+	assert.Equal(t, Owner{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "canary"}, owners[metrics.PodKey{Namespace: "default", Name: "canary-1"}])
+}
+
+func TestOwnerResolver_FailedLookups(t *testing.T) {
+	pods := []*corev1.Pod{
+		{ObjectMeta: controlledBy("canary-1", "apps/v1", "ReplicaSet", "canary-7c9b")},
+		{ObjectMeta: controlledBy("canary-2", "apps/v1", "ReplicaSet", "canary-7c9b")},
+		{ObjectMeta: controlledBy("canary-3", "apps/v1", "ReplicaSet", "canary-5f8d")},
+	}
+	cache := newTestCache(t,
+		&appsv1.ReplicaSet{ObjectMeta: controlledBy("canary-7c9b", "argoproj.io/v1alpha1", "Rollout", "canary")},
+		&appsv1.ReplicaSet{ObjectMeta: controlledBy("canary-5f8d", "argoproj.io/v1alpha1", "Rollout", "canary")},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("get", "rollouts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "argoproj.io", Resource: "rollouts"}, "canary", errors.New("rbac"))
+	})
+
+	// The pods still get the rollout, and its failed lookup is reported once
+	owners, err := NewOwnerResolver(cache, dynamicClient, rolloutMapper()).PodOwners(context.Background(), pods)
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.Len(t, strings.Split(err.Error(), "\n"), 1)
+	assert.Len(t, dynamicClient.Actions(), 1)
+	for _, pod := range pods {
+		assert.Equal(t, Owner{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "canary"}, owners[metrics.PodKey{Namespace: "default", Name: pod.Name}])
+	}
+}
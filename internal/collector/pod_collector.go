@@ -2,28 +2,97 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/hakongo/kubernetes-connector/internal/metrics"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+// errNoPodCollection is returned by a PodIndex no pod collection was
+// published to
+var errNoPodCollection = errors.New("the pod collector has not run")
+
+// PodIndex holds the pods of the last pod collection, with their usage, cost
+// and top-level controller, so the workload collector sums them without
+// reading the metrics sources again. It outlives the collectors, which are
+// recreated on every reconcile.
+type PodIndex struct {
+	mu        sync.RWMutex
+	pods      []ResourceMetrics
+	err       error
+	published bool
+}
+
+// NewPodIndex creates an empty pod index
+func NewPodIndex() *PodIndex {
+	return &PodIndex{}
+}
+
+// Publish replaces the pods with those of a pod collection. err is the
+// *DegradedError of the collection, nil when it read all usage and owners.
+func (x *PodIndex) Publish(pods []ResourceMetrics, err error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.pods = pods
+	x.err = err
+	x.published = true
+}
+
+// Reset forgets the published pods, such as when the pod collector no longer
+// runs
+func (x *PodIndex) Reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.pods = nil
+	x.err = nil
+	x.published = false
+}
+
+// Pods returns the pods of the last pod collection and the error it was
+// published with. The error is set when no collection was published.
+func (x *PodIndex) Pods() ([]ResourceMetrics, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if !x.published {
+		return nil, errNoPodCollection
+	}
+	return x.pods, x.err
+}
+
 type PodCollector struct {
-	kubeClient       kubernetes.Interface
+	cache            *Cache
+	metricsClient    versioned.Interface
 	prometheusClient *metrics.PrometheusClient
+	owners           *OwnerResolver
+	index            *PodIndex
 	config           CollectorConfig
 	usePrometheus    bool
+	useMetricsServer bool
 }
 
-func NewPodCollector(kubeClient kubernetes.Interface, prometheusClient *metrics.PrometheusClient, config CollectorConfig, usePrometheus bool) *PodCollector {
+// NewPodCollector creates a pod collector. Container usage is read from
+// Prometheus when usePrometheus is set, and from the Metrics Server for the
+// containers Prometheus has no usage for when useMetricsServer is set. Pods
+// are attributed to the top-level controller owners resolves them to, and to
+// none when owners is nil. Each collection is published to index unless it
+// is nil.
+func NewPodCollector(cache *Cache, metricsClient versioned.Interface, prometheusClient *metrics.PrometheusClient, owners *OwnerResolver, index *PodIndex, config CollectorConfig, usePrometheus bool, useMetricsServer bool) *PodCollector {
 	return &PodCollector{
-		kubeClient:       kubeClient,
+		cache:            cache,
+		metricsClient:    metricsClient,
 		prometheusClient: prometheusClient,
+		owners:           owners,
+		index:            index,
 		config:           config,
 		usePrometheus:    usePrometheus,
+		useMetricsServer: useMetricsServer,
 	}
 }
 
@@ -36,14 +105,44 @@ func (c *PodCollector) Description() string {
 }
 
 func (c *PodCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
-	pods, err := c.kubeClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	pods, err := c.cache.Pods(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
-	var metrics []ResourceMetrics
+	// The pods are still reported when their usage or owners cannot be read
+	var unavailable []error
 
-	for _, pod := range pods.Items {
+	// Fetch the usage of all containers at once and join it to the pods below
+	var podUsage map[metrics.PodKey]*metrics.ContainerMetrics
+	if c.usePrometheus && c.prometheusClient != nil {
+		podUsage, err = c.prometheusClient.GetClusterContainerMetrics(ctx, c.config.CollectionInterval)
+		if err != nil {
+			unavailable = append(unavailable, fmt.Errorf("failed to get container metrics from Prometheus: %w", err))
+		}
+	}
+
+	// The Metrics Server fills in the containers Prometheus has no usage for
+	var serverUsage map[metrics.PodKey]map[string]corev1.ResourceList
+	if c.useMetricsServer && c.metricsClient != nil {
+		serverUsage, err = c.metricsServerUsage(ctx)
+		if err != nil {
+			unavailable = append(unavailable, err)
+		}
+	}
+
+	var owners map[metrics.PodKey]Owner
+	if c.owners != nil {
+		owners, err = c.owners.PodOwners(ctx, pods)
+		if err != nil {
+			unavailable = append(unavailable, fmt.Errorf("failed to resolve pod owners: %w", err))
+		}
+	}
+
+	rates := c.config.CostRates.WithDefaults()
+	var result []ResourceMetrics
+
+	for _, pod := range pods {
 		// Skip pods in excluded namespaces
 		if contains(c.config.ExcludeNamespaces, pod.Namespace) {
 			continue
@@ -54,31 +153,6 @@ func (c *PodCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 			continue
 		}
 
-		// Get pod metrics from Prometheus if configured
-		var containerCPUMetrics map[string]float64
-		var containerMemoryMetrics map[string]float64
-		
-		// Initialize empty metrics
-		containerCPUMetrics = make(map[string]float64)
-		containerMemoryMetrics = make(map[string]float64)
-		
-		if c.usePrometheus && c.prometheusClient != nil {
-			// Try to get metrics from Prometheus
-			promContainerMetrics, err := c.prometheusClient.GetContainerMetrics(ctx, pod.Namespace, pod.Name)
-			if err != nil {
-				// Log error but continue collecting other pod metrics
-				fmt.Printf("Error getting metrics for pod %s/%s from Prometheus: %v\n", pod.Namespace, pod.Name, err)
-			} else {
-				// Copy metrics from Prometheus
-				for container, cpu := range promContainerMetrics.CPU {
-					containerCPUMetrics[container] = cpu
-				}
-				for container, memory := range promContainerMetrics.Memory {
-					containerMemoryMetrics[container] = memory
-				}
-			}
-		}
-
 		// Create pod metrics
 		podMetrics := ResourceMetrics{
 			Name:        pod.Name,
@@ -95,36 +169,134 @@ func (c *PodCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 		}
 
 		// Add container metrics
+		key := metrics.PodKey{Namespace: pod.Namespace, Name: pod.Name}
+		if owner, ok := owners[key]; ok {
+			podMetrics.OwnerKind = owner.Kind
+			podMetrics.OwnerName = owner.Name
+		}
+		usage := podUsage[key]
+		if usage != nil {
+			podMetrics.Network = newNetworkMetrics(usage.Network, c.config.CollectionInterval)
+		}
 		for _, container := range pod.Spec.Containers {
 			containerName := container.Name
-			cpuUsage := containerCPUMetrics[containerName]
-			memoryUsage := containerMemoryMetrics[containerName]
+			cpuUsage, memoryUsage, source := containerUsage(usage, serverUsage[key], containerName)
 
 			containerStatus := getContainerStatus(pod.Status.ContainerStatuses, containerName)
 
+			cpu := CPUMetrics{
+				UsageNanoCores:    int64(cpuUsage * 1e9), // Convert cores to nanocores
+				UsageCorePercent:  cpuUsage * 100,
+				RequestMilliCores: getResourceMilliValue(container.Resources.Requests, corev1.ResourceCPU),
+				LimitMilliCores:   getResourceMilliValue(container.Resources.Limits, corev1.ResourceCPU),
+			}
+			memory := MemoryMetrics{
+				UsageBytes:   int64(memoryUsage),
+				RequestBytes: getResourceByteValue(container.Resources.Requests, corev1.ResourceMemory),
+				LimitBytes:   getResourceByteValue(container.Resources.Limits, corev1.ResourceMemory),
+			}
+			if source == SourcePrometheus {
+				cpu.setStats(usage.CPUStats[containerName])
+				memory.setStats(usage.MemoryStats[containerName])
+				cpu.ThrottlingSeconds = usage.Throttling[containerName]
+				memory.RSSBytes = int64(usage.RSS[containerName])
+				memory.PageFaults = int64(usage.PageFaults[containerName])
+				memory.MajorPageFaults = int64(usage.MajorPageFaults[containerName])
+			}
+
 			podMetrics.Containers = append(podMetrics.Containers, ContainerMetrics{
-				Name: containerName,
-				CPU: CPUMetrics{
-					UsageNanoCores:    int64(cpuUsage * 1e9), // Convert cores to nanocores
-					UsageCorePercent:  cpuUsage * 100,
-					RequestMilliCores: getResourceMilliValue(container.Resources.Requests, corev1.ResourceCPU),
-					LimitMilliCores:   getResourceMilliValue(container.Resources.Limits, corev1.ResourceCPU),
-				},
-				Memory: MemoryMetrics{
-					UsageBytes:   int64(memoryUsage),
-					RequestBytes: getResourceByteValue(container.Resources.Requests, corev1.ResourceMemory),
-					LimitBytes:   getResourceByteValue(container.Resources.Limits, corev1.ResourceMemory),
-				},
+				Name:     containerName,
+				CPU:      cpu,
+				Memory:   memory,
 				Ready:    containerStatus.Ready,
 				Restarts: containerStatus.RestartCount,
 				State:    getContainerState(containerStatus.State),
+				Source:   source,
 			})
 		}
+		podMetrics.Cost = podCost(podMetrics.Containers, podMetrics.Network, rates)
+
+		result = append(result, podMetrics)
+	}
+
+	var degraded error
+	if len(unavailable) > 0 {
+		degraded = &DegradedError{Err: errors.Join(unavailable...)}
+	}
+	if c.index != nil {
+		c.index.Publish(result, degraded)
+	}
+	return result, degraded
+}
+
+// metricsServerUsage lists the usage of all pods in one request
+func (c *PodCollector) metricsServerUsage(ctx context.Context) (map[metrics.PodKey]map[string]corev1.ResourceList, error) {
+	podMetrics, err := c.metricsClient.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod metrics from the Metrics Server: %w", err)
+	}
+
+	usage := make(map[metrics.PodKey]map[string]corev1.ResourceList, len(podMetrics.Items))
+	for _, pod := range podMetrics.Items {
+		containers := make(map[string]corev1.ResourceList, len(pod.Containers))
+		for _, container := range pod.Containers {
+			containers[container.Name] = container.Usage
+		}
+		usage[metrics.PodKey{Namespace: pod.Namespace, Name: pod.Name}] = containers
+	}
+	return usage, nil
+}
 
-		metrics = append(metrics, podMetrics)
+// podCost estimates the hourly cost of a pod. Each container is billed for
+// the larger of its request and its usage, averaged over the collection
+// interval when available, as the node cannot give reserved resources to
+// other pods. All transmitted traffic is priced at the average bandwidth of
+// the interval, an upper bound of the egress cost left out of the total.
+func podCost(containers []ContainerMetrics, network NetworkMetrics, rates CostRates) CostMetrics {
+	var cores, memoryBytes float64
+	for _, container := range containers {
+		cpuUsage := float64(container.CPU.UsageNanoCores)
+		if container.CPU.UsageAvgNanoCores > 0 {
+			cpuUsage = float64(container.CPU.UsageAvgNanoCores)
+		}
+		cores += math.Max(cpuUsage/1e9, float64(container.CPU.RequestMilliCores)/1000)
+
+		memoryUsage := container.Memory.UsageBytes
+		if container.Memory.UsageAvgBytes > 0 {
+			memoryUsage = container.Memory.UsageAvgBytes
+		}
+		memoryBytes += float64(max(memoryUsage, container.Memory.RequestBytes))
 	}
 
-	return metrics, nil
+	cpuCost := cores * rates.CPUPerCoreHour
+	memoryCost := memoryBytes / float64(1<<30) * rates.MemoryPerGBHour
+	networkCost := network.TxBytesPerSecond * 3600 / float64(1<<30) * rates.NetworkPerGB
+	return CostMetrics{
+		Currency:    rates.Currency,
+		CPUCost:     cpuCost,
+		MemoryCost:  memoryCost,
+		NetworkCost: networkCost,
+		TotalCost:   cpuCost + memoryCost,
+	}
+}
+
+// containerUsage returns the CPU usage in cores and the memory usage in bytes
+// of a container and the source they were read from. Prometheus takes
+// precedence over the Metrics Server, and the usage of a container comes
+// from a single source.
+func containerUsage(prometheusUsage *metrics.ContainerMetrics, serverUsage map[string]corev1.ResourceList, container string) (float64, float64, string) {
+	if prometheusUsage != nil {
+		cpu, hasCPU := prometheusUsage.CPU[container]
+		memory, hasMemory := prometheusUsage.Memory[container]
+		if hasCPU || hasMemory {
+			return cpu, memory, SourcePrometheus
+		}
+	}
+	if usage, ok := serverUsage[container]; ok {
+		cpu := float64(usage.Cpu().ScaledValue(resource.Nano)) / 1e9
+		return cpu, float64(usage.Memory().Value()), SourceMetricsServer
+	}
+	return 0, 0, ""
 }
 
 func contains(slice []string, str string) bool {
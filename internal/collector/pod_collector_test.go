@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestContainerUsage_Precedence(t *testing.T) {
+	prometheusUsage := &metrics.ContainerMetrics{
+		CPU:    map[string]float64{"app": 0.5},
+		Memory: map[string]float64{"app": 1 << 20},
+	}
+	serverUsage := map[string]corev1.ResourceList{
+		"app": {
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("2Mi"),
+		},
+		"sidecar": {
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+
+	cpu, memory, source := containerUsage(prometheusUsage, serverUsage, "app")
+	assert.Equal(t, SourcePrometheus, source, "Prometheus takes precedence")
+	assert.Equal(t, 0.5, cpu)
+	assert.Equal(t, float64(1<<20), memory)
+
+	cpu, memory, source = containerUsage(prometheusUsage, serverUsage, "sidecar")
+	assert.Equal(t, SourceMetricsServer, source, "the Metrics Server fills in missing containers")
+	assert.Equal(t, 0.25, cpu)
+	assert.Equal(t, float64(64<<20), memory)
+
+	cpu, memory, source = containerUsage(nil, nil, "app")
+	assert.Empty(t, source)
+	assert.Zero(t, cpu)
+	assert.Zero(t, memory)
+}
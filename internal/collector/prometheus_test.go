@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakePrometheus answers every query with one series per pod or node of the
+// test cluster and counts the queries it receives. Instant queries return
+// one sample per series and range queries return rangeValues.
+type fakePrometheus struct {
+	*httptest.Server
+	queries atomic.Int64
+}
+
+// rangeValues are the samples of every series returned by range queries
+var rangeValues = []string{"0.1", "0.2", "0.3", "0.4", "1"}
+
+func newFakePrometheus(t testing.TB, pods, nodes int) *fakePrometheus {
+	p := &fakePrometheus{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.queries.Add(1)
+		query := r.FormValue("query")
+
+		isRange := strings.HasSuffix(r.URL.Path, "/query_range")
+		sample := func(labels map[string]string, value string) map[string]interface{} {
+			if !isRange {
+				return map[string]interface{}{"metric": labels, "value": []interface{}{0, value}}
+			}
+			values := make([]interface{}, len(rangeValues))
+			for i, v := range rangeValues {
+				values[i] = []interface{}{i * 15, v}
+			}
+			return map[string]interface{}{"metric": labels, "values": values}
+		}
+
+		var result []map[string]interface{}
+		switch {
+		case strings.Contains(query, "container_network_"):
+			for i := 0; i < pods; i++ {
+				pod := fmt.Sprintf("pod-%d", i)
+				result = append(result,
+					sample(map[string]string{"namespace": "default", "pod": pod, "traffic": "rx_bytes"}, "1024"),
+					sample(map[string]string{"namespace": "default", "pod": pod, "traffic": "tx_bytes"}, "2048"))
+			}
+		case strings.Contains(query, "node_network_"):
+			for i := 0; i < nodes; i++ {
+				result = append(result, sample(map[string]string{"node": fmt.Sprintf("node-%d", i), "traffic": "tx_bytes"}, "4096"))
+			}
+		case strings.Contains(query, "container_memory_failures_total"):
+			for i := 0; i < pods; i++ {
+				pod := fmt.Sprintf("pod-%d", i)
+				result = append(result,
+					sample(map[string]string{"namespace": "default", "pod": pod, "container": "app", "failure_type": "pgfault"}, "12"),
+					sample(map[string]string{"namespace": "default", "pod": pod, "container": "app", "failure_type": "pgmajfault"}, "3"))
+			}
+		case strings.Contains(query, "container_"):
+			value := "0.25"
+			switch {
+			case strings.Contains(query, "container_cpu_cfs_throttled_seconds_total"):
+				value = "1.5"
+			case strings.Contains(query, "container_memory_rss"):
+				value = "1048576"
+			}
+			for i := 0; i < pods; i++ {
+				result = append(result, sample(map[string]string{"namespace": "default", "pod": fmt.Sprintf("pod-%d", i), "container": "app"}, value))
+			}
+		case strings.Contains(query, "node_"):
+			for i := 0; i < nodes; i++ {
+				result = append(result, sample(map[string]string{"node": fmt.Sprintf("node-%d", i)}, "2"))
+			}
+		}
+
+		resultType := "vector"
+		if isRange {
+			resultType = "matrix"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": resultType, "result": result},
+		})
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func testPods(count int) []runtime.Object {
+	objects := make([]runtime.Object, 0, count)
+	for i := 0; i < count; i++ {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i), Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		})
+	}
+	return objects
+}
+
+func testNodes(count int) []runtime.Object {
+	objects := make([]runtime.Object, 0, count)
+	for i := 0; i < count; i++ {
+		objects = append(objects, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+		})
+	}
+	return objects
+}
+
+func TestPodCollector_PrometheusQueriesPerCycle(t *testing.T) {
+	for _, size := range []int{1, 10, 200} {
+		t.Run(fmt.Sprintf("%d pods", size), func(t *testing.T) {
+			prom := newFakePrometheus(t, size, 0)
+			promClient, err := metrics.NewPrometheusClient(prom.URL)
+			assert.NoError(t, err)
+
+			c := NewPodCollector(newTestCache(t, testPods(size)...), nil, promClient, nil, nil, CollectorConfig{}, true, false)
+			result, err := c.Collect(context.Background())
+			assert.NoError(t, err)
+
+			// One query each for CPU, memory, throttling, RSS, page faults
+			// and network, whatever the cluster size
+			assert.Equal(t, int64(6), prom.queries.Load())
+			assert.Len(t, result, size)
+			for _, pod := range result {
+				container := pod.Containers[0]
+				assert.Equal(t, int64(250000000), container.CPU.UsageNanoCores)
+				assert.Equal(t, 1.5, container.CPU.ThrottlingSeconds)
+				assert.Equal(t, int64(1048576), container.Memory.RSSBytes)
+				assert.Equal(t, int64(12), container.Memory.PageFaults)
+				assert.Equal(t, int64(3), container.Memory.MajorPageFaults)
+				assert.Equal(t, 1024.0, pod.Network.RxBytesPerSecond)
+				assert.Equal(t, 2048.0, pod.Network.TxBytesPerSecond)
+			}
+		})
+	}
+}
+
+func TestNodeCollector_PrometheusQueriesPerCycle(t *testing.T) {
+	for _, size := range []int{1, 50} {
+		t.Run(fmt.Sprintf("%d nodes", size), func(t *testing.T) {
+			prom := newFakePrometheus(t, 0, size)
+			promClient, err := metrics.NewPrometheusClient(prom.URL)
+			assert.NoError(t, err)
+
+			c := NewNodeCollector(newTestCache(t, testNodes(size)...), nil, promClient, CollectorConfig{}, true, false)
+			result, err := c.Collect(context.Background())
+			assert.NoError(t, err)
+
+			assert.Equal(t, int64(3), prom.queries.Load())
+			assert.Len(t, result, size)
+			for _, node := range result {
+				assert.Equal(t, int64(2e9), node.CPU.UsageNanoCores)
+				assert.Equal(t, 4096.0, node.Network.TxBytesPerSecond)
+			}
+		})
+	}
+}
+
+func TestNodeCollector_PrometheusUnavailable(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer prom.Close()
+	promClient, err := metrics.NewPrometheusClient(prom.URL)
+	assert.NoError(t, err)
+
+	// The nodes are still reported, with the failure in the status
+	c := NewNodeCollector(newTestCache(t, testNodes(2)...), nil, promClient, CollectorConfig{}, true, false)
+	result, err := c.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+	assert.ErrorContains(t, err, "node metrics from Prometheus")
+	assert.Len(t, result, 2)
+}
+
+func TestPodCollector_PrometheusWindowStats(t *testing.T) {
+	prom := newFakePrometheus(t, 3, 0)
+	promClient, err := metrics.NewPrometheusClient(prom.URL)
+	assert.NoError(t, err)
+
+	c := NewPodCollector(newTestCache(t, testPods(3)...), nil, promClient, nil, nil, CollectorConfig{CollectionInterval: time.Minute}, true, false)
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+
+	// A range query for CPU, memory and network and an instant query each
+	// for throttling, RSS and page faults still cover the whole cluster
+	assert.Equal(t, int64(6), prom.queries.Load())
+	assert.Len(t, result, 3)
+	for _, pod := range result {
+		cpu := pod.Containers[0].CPU
+		assert.Equal(t, int64(1e9), cpu.UsageNanoCores, "usage is the latest sample")
+		assert.Equal(t, int64(400000000), cpu.UsageAvgNanoCores)
+		assert.Equal(t, int64(300000000), cpu.UsageP50NanoCores)
+		assert.InDelta(t, 880000000, cpu.UsageP95NanoCores, 1)
+		assert.Equal(t, int64(1e9), cpu.UsageMaxNanoCores)
+		assert.Equal(t, int64(1), pod.Containers[0].Memory.UsageMaxBytes)
+		assert.Equal(t, 1.5, cpu.ThrottlingSeconds, "throttling is not a range query")
+
+		// Network rates are averaged over the interval and the counters
+		// hold the traffic of the interval
+		assert.InDelta(t, 0.4, pod.Network.TxBytesPerSecond, 1e-9)
+		assert.Equal(t, int64(24), pod.Network.TxBytes)
+	}
+}
+
+func TestNodeCollector_CostUsesWindowAverage(t *testing.T) {
+	prom := newFakePrometheus(t, 0, 1)
+	promClient, err := metrics.NewPrometheusClient(prom.URL)
+	assert.NoError(t, err)
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}},
+	}
+	c := NewNodeCollector(newTestCache(t, node), nil, promClient, CollectorConfig{
+		CollectionInterval: time.Minute,
+		CostRates:          CostRates{NetworkPerGB: 0.09},
+	}, true, false)
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	assert.Equal(t, int64(1e9), result[0].CPU.UsageNanoCores)
+	assert.Equal(t, int64(400000000), result[0].CPU.UsageAvgNanoCores)
+
+	instant := c.calculateCostMetrics(CPUMetrics{UsageCorePercent: 1}, MemoryMetrics{}, NetworkMetrics{}, node)
+	assert.InDelta(t, instant.CPUCost*0.4, result[0].Cost.CPUCost, 1e-12)
+
+	// Transmitted traffic is priced at the average bandwidth of the interval,
+	// but stays out of the total as it includes in-cluster traffic
+	assert.InDelta(t, 0.4*3600/float64(1<<30)*0.09, result[0].Cost.NetworkCost, 1e-15)
+	assert.NotZero(t, result[0].Cost.NetworkCost)
+	assert.InDelta(t, result[0].Cost.CPUCost+result[0].Cost.MemoryCost, result[0].Cost.TotalCost, 1e-12)
+}
+
+// BenchmarkPodCollector_Prometheus reports the Prometheus queries per
+// collection, which stays constant as the cluster grows
+func BenchmarkPodCollector_Prometheus(b *testing.B) {
+	for _, size := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("pods=%d", size), func(b *testing.B) {
+			prom := newFakePrometheus(b, size, 0)
+			promClient, err := metrics.NewPrometheusClient(prom.URL)
+			if err != nil {
+				b.Fatal(err)
+			}
+			c := NewPodCollector(newTestCache(b, testPods(size)...), nil, promClient, nil, nil, CollectorConfig{}, true, false)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Collect(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(prom.queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -2,26 +2,39 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 type PVCollector struct {
-	kubeClient    kubernetes.Interface
-	metricsClient versioned.Interface
-	config        CollectorConfig
+	cache            *Cache
+	metricsClient    versioned.Interface
+	prometheusClient *metrics.PrometheusClient
+	kubeletClient    *metrics.KubeletClient
+	config           CollectorConfig
+	useKubelet       bool
 }
 
-func NewPVCollector(kubeClient kubernetes.Interface, metricsClient versioned.Interface, config CollectorConfig) *PVCollector {
+// NewPVCollector creates a PV collector. Volume usage is read from the
+// kubelet volume stats in Prometheus when prometheusClient is set. When
+// useKubelet is set, it is read from the kubelet stats summary of each node,
+// through kubeClient, if Prometheus cannot provide it. Otherwise only the
+// capacity of mounted volumes is reported.
+func NewPVCollector(kubeClient kubernetes.Interface, cache *Cache, metricsClient versioned.Interface, prometheusClient *metrics.PrometheusClient, config CollectorConfig, useKubelet bool) *PVCollector {
 	return &PVCollector{
-		kubeClient:    kubeClient,
-		metricsClient: metricsClient,
-		config:        config,
+		cache:            cache,
+		metricsClient:    metricsClient,
+		prometheusClient: prometheusClient,
+		kubeletClient:    metrics.NewKubeletClient(kubeClient),
+		config:           config,
+		useKubelet:       useKubelet,
 	}
 }
 
@@ -32,27 +45,37 @@ func (pc *PVCollector) Description() string {
 }
 
 func (pc *PVCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
-	var metrics []ResourceMetrics
+	var result []ResourceMetrics
 
-	pvs, err := pc.kubeClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	pvs, err := pc.cache.PersistentVolumes(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list persistent volumes: %w", err)
 	}
 
-	pvcs, err := pc.kubeClient.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	pvcs, err := pc.cache.PersistentVolumeClaims(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list persistent volume claims: %w", err)
 	}
 
 	// Create a map of PVC name to PVC for quick lookups
 	pvcMap := make(map[string]*corev1.PersistentVolumeClaim)
-	for i := range pvcs.Items {
-		pvc := &pvcs.Items[i]
+	for _, pvc := range pvcs {
 		key := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
 		pvcMap[key] = pvc
 	}
 
-	for _, pv := range pvs.Items {
+	// Volume usage is only known for bound volumes. The volumes are still
+	// reported when their stats cannot be read.
+	var claimStats map[metrics.ClaimKey]*metrics.ClaimStats
+	var statsErr error
+	for _, pv := range pvs {
+		if pv.Status.Phase == corev1.VolumeBound {
+			claimStats, statsErr = pc.claimStats(ctx)
+			break
+		}
+	}
+
+	for _, pv := range pvs {
 		metric := ResourceMetrics{
 			Name:        pv.Name,
 			Kind:        "PersistentVolume",
@@ -61,10 +84,14 @@ func (pc *PVCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 		}
 
 		// Calculate storage metrics
-		metric.Storage = pc.calculateStorageMetrics(&pv)
+		var stats *metrics.ClaimStats
+		if pv.Spec.ClaimRef != nil {
+			stats = claimStats[metrics.ClaimKey{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}]
+		}
+		metric.Storage = pc.calculateStorageMetrics(pv, stats)
 
 		// Calculate cost metrics based on storage class and capacity
-		metric.Cost = pc.calculateCostMetrics(&pv)
+		metric.Cost = pc.calculateCostMetrics(pv)
 
 		// If PV is bound to a PVC, include PVC details
 		if pv.Spec.ClaimRef != nil {
@@ -75,23 +102,103 @@ func (pc *PVCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 			}
 		}
 
-		metrics = append(metrics, metric)
+		result = append(result, metric)
 	}
 
-	return metrics, nil
+	if statsErr != nil {
+		return result, &DegradedError{Err: statsErr}
+	}
+	return result, nil
 }
 
-func (pc *PVCollector) calculateStorageMetrics(pv *corev1.PersistentVolume) StorageMetrics {
+// claimStats returns the volume usage of every mounted claim from
+// Prometheus. The kubelets are read instead, when enabled, if Prometheus is
+// not configured, fails or has no volume stats query. An empty answer from
+// Prometheus is kept, so clusters without mounted claims do not read every
+// kubelet. The error is set when the stats of some claims could not be read.
+func (pc *PVCollector) claimStats(ctx context.Context) (map[metrics.ClaimKey]*metrics.ClaimStats, error) {
+	var promErr error
+	if pc.prometheusClient != nil {
+		// The stats are nil when the query set has no volume stats query
+		stats, err := pc.prometheusClient.GetClusterVolumeStats(ctx)
+		if err != nil {
+			promErr = fmt.Errorf("failed to get volume stats from Prometheus: %w", err)
+		} else if stats != nil {
+			return stats, nil
+		}
+	}
+	if !pc.useKubelet {
+		return nil, promErr
+	}
+	return pc.kubeletClaimStats(ctx)
+}
+
+// kubeletClaimStats reads the volume stats from the stats summary of every
+// node. Nodes whose summary cannot be read are skipped and reported in the
+// error.
+func (pc *PVCollector) kubeletClaimStats(ctx context.Context) (map[metrics.ClaimKey]*metrics.ClaimStats, error) {
+	nodes, err := pc.cache.Nodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes for volume stats: %w", err)
+	}
+
+	concurrency := pc.config.MaxConcurrentCollections
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed []error
+	claims := make(map[metrics.ClaimKey]*metrics.ClaimStats)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			summary, err := pc.kubeletClient.GetSummary(ctx, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, err)
+				return
+			}
+			for key, stats := range summary.ClaimStats() {
+				claims[key] = stats
+			}
+		}(node.Name)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return claims, fmt.Errorf("failed to read the volume stats of %d of %d nodes: %w",
+			len(failed), len(nodes), errors.Join(failed...))
+	}
+	return claims, nil
+}
+
+// calculateStorageMetrics returns the capacity of the volume and, when the
+// volume is mounted, its filesystem usage. Bound volumes without stats are
+// flagged, so their zero usage is not mistaken for an empty volume.
+func (pc *PVCollector) calculateStorageMetrics(pv *corev1.PersistentVolume, stats *metrics.ClaimStats) StorageMetrics {
 	storage := StorageMetrics{
 		CapacityBytes: pv.Spec.Capacity.Storage().Value(),
 	}
 
-	// Set usage based on phase
-	switch pv.Status.Phase {
-	case corev1.VolumeBound:
-		storage.UsageBytes = storage.CapacityBytes // Assume full usage when bound
-	case corev1.VolumeAvailable:
+	switch {
+	case stats != nil:
+		storage.UsageBytes = int64(stats.UsedBytes)
+		storage.Available = int64(stats.AvailableBytes)
+		storage.Inodes = int64(stats.Inodes)
+		storage.InodesUsed = int64(stats.InodesUsed)
+		storage.InodesFree = int64(stats.InodesFree)
+	case pv.Status.Phase == corev1.VolumeAvailable:
 		storage.Available = storage.CapacityBytes
+	case pv.Status.Phase == corev1.VolumeBound:
+		storage.UsageUnknown = true
 	}
 
 	return storage
@@ -100,17 +207,10 @@ func (pc *PVCollector) calculateStorageMetrics(pv *corev1.PersistentVolume) Stor
 func (pc *PVCollector) calculateCostMetrics(pv *corev1.PersistentVolume) CostMetrics {
 	// Base storage cost calculation
 	storageGB := float64(pv.Spec.Capacity.Storage().Value()) / float64(1<<30)
-	var costPerGBHour float64
 
 	// Adjust cost based on storage class
-	switch {
-	case pv.Spec.StorageClassName == "premium-ssd":
-		costPerGBHour = 0.17 // Premium SSD cost
-	case pv.Spec.StorageClassName == "standard-ssd":
-		costPerGBHour = 0.08 // Standard SSD cost
-	default:
-		costPerGBHour = 0.04 // Standard HDD cost
-	}
+	rates := pc.config.CostRates.WithDefaults()
+	costPerGBHour := rates.StorageRate(pv.Spec.StorageClassName)
 
 	storageCost := storageGB * costPerGBHour
 
@@ -120,7 +220,7 @@ func (pc *PVCollector) calculateCostMetrics(pv *corev1.PersistentVolume) CostMet
 	}
 
 	return CostMetrics{
-		Currency:    "USD",
+		Currency:    rates.Currency,
 		StorageCost: storageCost,
 		TotalCost:   storageCost,
 	}
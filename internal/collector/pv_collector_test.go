@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func testVolumes() []runtime.Object {
+	return []runtime.Object{
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+				ClaimRef: &corev1.ObjectReference{Namespace: "default", Name: "data-db-0"},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-spare"},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable},
+		},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-db-0", Namespace: "default"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+	}
+}
+
+func TestPVCollector_PrometheusVolumeStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result []map[string]interface{}
+		for stat, value := range map[string]string{"used_bytes": "2147483648", "available_bytes": "8589934592", "inodes_used": "300", "inodes_free": "700", "inodes": "1000"} {
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{"namespace": "default", "persistentvolumeclaim": "data-db-0", "stat": stat},
+				"value":  []interface{}{0, value},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	defer server.Close()
+	promClient, err := metrics.NewPrometheusClient(server.URL)
+	assert.NoError(t, err)
+
+	c := NewPVCollector(fake.NewSimpleClientset(), newTestCache(t, testVolumes()...), nil, promClient, CollectorConfig{}, false)
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	bound := result[0]
+	assert.Equal(t, "pv-data", bound.Name)
+	assert.Equal(t, "data-db-0", bound.Storage.PVCName)
+	assert.Equal(t, int64(10<<30), bound.Storage.CapacityBytes)
+	assert.Equal(t, int64(2<<30), bound.Storage.UsageBytes)
+	assert.Equal(t, int64(8<<30), bound.Storage.Available)
+	assert.Equal(t, int64(300), bound.Storage.InodesUsed)
+
+	spare := result[1]
+	assert.Zero(t, spare.Storage.UsageBytes)
+	assert.Equal(t, int64(5<<30), spare.Storage.Available)
+}
+
+func TestPVCollector_KubeletVolumeStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pods":[{"podRef":{"name":"db-0","namespace":"default"},"volume":[{"name":"data","pvcRef":{"name":"data-db-0","namespace":"default"},"usedBytes":1073741824,"availableBytes":9663676416}]}]}`)
+	}))
+	defer server.Close()
+	proxyClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+
+	// Without Prometheus the stats come from the kubelet of each node
+	c := NewPVCollector(fake.NewSimpleClientset(), newTestCache(t, testVolumes()...), nil, nil, CollectorConfig{}, true)
+	c.kubeletClient = metrics.NewKubeletClient(proxyClient)
+	result, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(1<<30), result[0].Storage.UsageBytes)
+	assert.Equal(t, int64(9663676416), result[0].Storage.Available)
+}
+
+func TestPVCollector_KubeletFallback(t *testing.T) {
+	var summaries atomic.Int32
+	kubelet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		summaries.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pods":[]}`)
+	}))
+	defer kubelet.Close()
+	proxyClient, err := kubernetes.NewForConfig(&rest.Config{Host: kubelet.URL})
+	assert.NoError(t, err)
+
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	defer prom.Close()
+	promClient, err := metrics.NewPrometheusClient(prom.URL)
+	assert.NoError(t, err)
+
+	collect := func(promClient *metrics.PrometheusClient, useKubelet bool) {
+		c := NewPVCollector(fake.NewSimpleClientset(), newTestCache(t, testVolumes()...), nil, promClient, CollectorConfig{}, useKubelet)
+		c.kubeletClient = metrics.NewKubeletClient(proxyClient)
+		result, err := c.Collect(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10<<30), result[0].Storage.CapacityBytes)
+	}
+
+	// The kubelets are only read when enabled
+	collect(nil, false)
+	assert.Zero(t, summaries.Load())
+
+	// An empty answer from Prometheus means no claim is mounted
+	collect(promClient, true)
+	assert.Zero(t, summaries.Load())
+
+	collect(nil, true)
+	assert.Equal(t, int32(1), summaries.Load())
+}
+
+func TestPVCollector_UnknownUsage(t *testing.T) {
+	// A bound volume whose usage is unknown is not reported as full
+	c := NewPVCollector(fake.NewSimpleClientset(), newTestCache(t), nil, nil, CollectorConfig{}, false)
+	storage := c.calculateStorageMetrics(testVolumes()[0].(*corev1.PersistentVolume), nil)
+	assert.Zero(t, storage.UsageBytes)
+	assert.True(t, storage.UsageUnknown, "zero usage is not mistaken for an empty volume")
+	assert.Equal(t, int64(10<<30), storage.CapacityBytes)
+}
+
+func TestPVCollector_FailedStats(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer prom.Close()
+	promClient, err := metrics.NewPrometheusClient(prom.URL)
+	assert.NoError(t, err)
+
+	// The volumes are still reported, with the failure in the status
+	c := NewPVCollector(fake.NewSimpleClientset(), newTestCache(t, testVolumes()...), nil, promClient, CollectorConfig{}, false)
+	result, err := c.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+	assert.ErrorContains(t, err, "volume stats from Prometheus")
+	assert.Len(t, result, 2)
+	assert.True(t, result[0].Storage.UsageUnknown)
+	assert.False(t, result[1].Storage.UsageUnknown, "available volumes are known to be empty")
+}
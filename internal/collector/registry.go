@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// Dependencies are the clients and settings shared by all collectors
+type Dependencies struct {
+	KubeClient       kubernetes.Interface
+	Cache            *Cache
+	MetricsClient    versioned.Interface
+	PrometheusClient *metrics.PrometheusClient
+	UsePrometheus    bool
+	UseMetricsServer bool
+
+	// Owners resolves pods to their top-level controllers
+	Owners *OwnerResolver
+
+	// Pods holds the last pod collection for the workload collector
+	Pods *PodIndex
+
+	// CustomMetricRuns tracks the intervals of the custom metric queries
+	CustomMetricRuns *RunTracker
+
+	// CustomMetrics holds the custom metric values attached to the records
+	// of the other collectors
+	CustomMetrics *CustomMetricIndex
+
+	// UseKubelet is set when the kubelet stats summary of every node may be
+	// read
+	UseKubelet bool
+
+	// KubeletMaxConcurrentNodes limits the stats summaries read in parallel
+	KubeletMaxConcurrentNodes int
+
+	// KubeletCounters tracks the cumulative counters of the kubelet stats
+	KubeletCounters *CounterTracker
+}
+
+// Factory creates a collector
+type Factory func(deps Dependencies, config CollectorConfig) Collector
+
+// Registry maps the collector names used in the ConnectorConfig spec to
+// collector factories
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// DefaultRegistry returns a registry with the built-in collectors
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("pod", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewPodCollector(deps.Cache, deps.MetricsClient, deps.PrometheusClient, deps.Owners, deps.Pods, config, deps.UsePrometheus, deps.UseMetricsServer)
+	})
+	r.Register("node", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewNodeCollector(deps.Cache, deps.MetricsClient, deps.PrometheusClient, config, deps.UsePrometheus, deps.UseMetricsServer)
+	})
+	r.Register("pv", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewPVCollector(deps.KubeClient, deps.Cache, deps.MetricsClient, deps.PrometheusClient, config, deps.UseKubelet)
+	})
+	r.Register("service", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewServiceCollector(deps.Cache, deps.MetricsClient, config)
+	})
+	r.Register("namespace", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewNamespaceCollector(deps.Cache, config)
+	})
+	r.Register("workload", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewWorkloadCollector(deps.Cache, deps.Pods, config)
+	})
+	r.Register("ingress", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewIngressCollector(deps.Cache, config)
+	})
+	r.Register("event", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewEventCollector(deps.Cache, config)
+	})
+	r.Register("custom", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewCustomMetricsCollector(deps.PrometheusClient, config, deps.CustomMetricRuns, deps.CustomMetrics)
+	})
+	r.Register("kubelet", func(deps Dependencies, config CollectorConfig) Collector {
+		return NewKubeletCollector(deps.KubeClient, deps.Cache, config, deps.KubeletMaxConcurrentNodes, deps.KubeletCounters)
+	})
+	return r
+}
+
+// Register adds a collector factory under name, replacing any existing one
+func (r *Registry) Register(name string, factory Factory) {
+	r.factories[name] = factory
+}
+
+// Has reports whether a collector is registered under name
+func (r *Registry) Has(name string) bool {
+	_, ok := r.factories[name]
+	return ok
+}
+
+// Names returns the registered collector names in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the collector registered under name
+func (r *Registry) New(name string, deps Dependencies, config CollectorConfig) (Collector, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collector %q", name)
+	}
+	return factory(deps, config), nil
+}
+
+// labeledCollector adds a fixed set of labels to the output of a collector
+type labeledCollector struct {
+	Collector
+	labels map[string]string
+}
+
+// WithLabels wraps c so that every resource it returns carries labels.
+// The labels take precedence over the resource's own labels. c is returned
+// unchanged when labels is empty.
+func WithLabels(c Collector, labels map[string]string) Collector {
+	if len(labels) == 0 {
+		return c
+	}
+	return &labeledCollector{Collector: c, labels: labels}
+}
+
+// Collect runs the wrapped collector and adds the labels to its output
+func (c *labeledCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
+	metrics, err := c.Collector.Collect(ctx)
+	if err != nil && !IsDegraded(err) {
+		return nil, err
+	}
+
+	for i := range metrics {
+		labels := make(map[string]string, len(metrics[i].Labels)+len(c.labels))
+		for k, v := range metrics[i].Labels {
+			labels[k] = v
+		}
+		for k, v := range c.labels {
+			labels[k] = v
+		}
+		metrics[i].Labels = labels
+	}
+	// Degraded runs still return their error for the breaker
+	return metrics, err
+}
+
+// customMetricsCollector attaches the custom metric values to the output of
+// a collector
+type customMetricsCollector struct {
+	Collector
+	index *CustomMetricIndex
+}
+
+// WithCustomMetrics wraps c so that the resources it returns carry the
+// custom metric values published to index. c is returned unchanged when
+// index is nil.
+func WithCustomMetrics(c Collector, index *CustomMetricIndex) Collector {
+	if index == nil {
+		return c
+	}
+	return &customMetricsCollector{Collector: c, index: index}
+}
+
+// Collect runs the wrapped collector and attaches the custom metric values
+// to its output
+func (c *customMetricsCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
+	metrics, err := c.Collector.Collect(ctx)
+	if err != nil && !IsDegraded(err) {
+		return nil, err
+	}
+
+	// The pod collector shares its records with the workload collector, so
+	// the values are set on a copy
+	metrics = slices.Clone(metrics)
+	c.index.Attach(metrics)
+	return metrics, err
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticCollector struct {
+	metrics []ResourceMetrics
+	err     error
+}
+
+func (c *staticCollector) Collect(context.Context) ([]ResourceMetrics, error) { return c.metrics, c.err }
+func (c *staticCollector) Name() string                                      { return "static" }
+func (c *staticCollector) Description() string                               { return "returns fixed metrics" }
+
+func TestDefaultRegistry(t *testing.T) {
+	r := DefaultRegistry()
+	assert.Equal(t, []string{"custom", "event", "ingress", "kubelet", "namespace", "node", "pod", "pv", "service", "workload"}, r.Names())
+
+	c, err := r.New("namespace", Dependencies{Cache: newTestCache(t)}, CollectorConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "namespace-collector", c.Name())
+
+	_, err = r.New("gpu", Dependencies{}, CollectorConfig{})
+	assert.EqualError(t, err, `unknown collector "gpu"`)
+	assert.False(t, r.Has("gpu"))
+}
+
+func TestWithLabels(t *testing.T) {
+	inner := &staticCollector{metrics: []ResourceMetrics{
+		{Name: "a", Labels: map[string]string{"app": "web", "team": "old"}},
+		{Name: "b"},
+	}}
+
+	c := WithLabels(inner, map[string]string{"team": "platform"})
+	metrics, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "web", "team": "platform"}, metrics[0].Labels)
+	assert.Equal(t, map[string]string{"team": "platform"}, metrics[1].Labels)
+	assert.Equal(t, "static", c.Name())
+
+	assert.Same(t, inner, WithLabels(inner, nil))
+}
+
+func TestWithLabels_Degraded(t *testing.T) {
+	degraded := &DegradedError{Err: errors.New("node-2 unreachable")}
+	inner := &staticCollector{metrics: []ResourceMetrics{{Name: "a"}}, err: degraded}
+	b := NewBreaker(DefaultBreakerConfig())
+
+	// The breaker outside the labels still sees the partial failure
+	metrics, err := WithBreaker(WithLabels(inner, map[string]string{"team": "platform"}), b).Collect(context.Background())
+	assert.Same(t, degraded, err)
+	assert.Equal(t, map[string]string{"team": "platform"}, metrics[0].Labels)
+	assert.Equal(t, degraded, b.Health().Degraded)
+
+	inner.err = errors.New("boom")
+	metrics, err = WithLabels(inner, map[string]string{"team": "platform"}).Collect(context.Background())
+	assert.EqualError(t, err, "boom")
+	assert.Nil(t, metrics)
+}
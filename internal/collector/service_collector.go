@@ -6,20 +6,18 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 type ServiceCollector struct {
-	kubeClient    kubernetes.Interface
+	cache         *Cache
 	metricsClient versioned.Interface
 	config        CollectorConfig
 }
 
-func NewServiceCollector(kubeClient kubernetes.Interface, metricsClient versioned.Interface, config CollectorConfig) *ServiceCollector {
+func NewServiceCollector(cache *Cache, metricsClient versioned.Interface, config CollectorConfig) *ServiceCollector {
 	return &ServiceCollector{
-		kubeClient:    kubeClient,
+		cache:         cache,
 		metricsClient: metricsClient,
 		config:        config,
 	}
@@ -32,26 +30,25 @@ func (sc *ServiceCollector) Description() string { return "Collects metrics for
 func (sc *ServiceCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 	var metrics []ResourceMetrics
 
-	services, err := sc.kubeClient.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	services, err := sc.cache.Services(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
 
 	// Get endpoints to check service health and connectivity
-	endpoints, err := sc.kubeClient.CoreV1().Endpoints("").List(ctx, metav1.ListOptions{})
+	endpoints, err := sc.cache.Endpoints(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list endpoints: %w", err)
 	}
 
 	// Create endpoints map for quick lookups
 	endpointMap := make(map[string]*corev1.Endpoints)
-	for i := range endpoints.Items {
-		endpoint := &endpoints.Items[i]
+	for _, endpoint := range endpoints {
 		key := fmt.Sprintf("%s/%s", endpoint.Namespace, endpoint.Name)
 		endpointMap[key] = endpoint
 	}
 
-	for _, svc := range services.Items {
+	for _, svc := range services {
 		// Skip services in excluded namespaces
 		if sc.shouldSkipNamespace(svc.Namespace) {
 			continue
@@ -65,11 +62,11 @@ func (sc *ServiceCollector) Collect(ctx context.Context) ([]ResourceMetrics, err
 			CollectedAt: time.Now(),
 		}
 
-		// Calculate network metrics based on service type and endpoints
-		metric.Network = sc.calculateNetworkMetrics(&svc, endpointMap[fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)])
+		// Report the endpoints and external ingress points of the service
+		metric.Status = sc.serviceStatus(svc, endpointMap[fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)])
 
 		// Calculate cost based on service type and configuration
-		metric.Cost = sc.calculateCostMetrics(&svc)
+		metric.Cost = sc.calculateCostMetrics(svc)
 
 		metrics = append(metrics, metric)
 	}
@@ -100,25 +97,21 @@ func (sc *ServiceCollector) shouldSkipNamespace(namespace string) bool {
 	return true
 }
 
-func (sc *ServiceCollector) calculateNetworkMetrics(svc *corev1.Service, endpoints *corev1.Endpoints) NetworkMetrics {
-	var net NetworkMetrics
-
-	// Check if service has endpoints
+func (sc *ServiceCollector) serviceStatus(svc *corev1.Service, endpoints *corev1.Endpoints) map[string]interface{} {
+	var ready, notReady int
 	if endpoints != nil {
 		for _, subset := range endpoints.Subsets {
-			net.TxPackets += int64(len(subset.Addresses)) // Count ready endpoints
-			if len(subset.NotReadyAddresses) > 0 {
-				net.TxErrors += int64(len(subset.NotReadyAddresses))
-			}
+			ready += len(subset.Addresses)
+			notReady += len(subset.NotReadyAddresses)
 		}
 	}
 
-	// Check if service has external IP
-	if len(svc.Status.LoadBalancer.Ingress) > 0 {
-		net.RxPackets++ // Increment to indicate external connectivity
+	return map[string]interface{}{
+		"type":              string(svc.Spec.Type),
+		"readyEndpoints":    ready,
+		"notReadyEndpoints": notReady,
+		"ingressPoints":     len(svc.Status.LoadBalancer.Ingress),
 	}
-
-	return net
 }
 
 func (sc *ServiceCollector) calculateCostMetrics(svc *corev1.Service) CostMetrics {
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceCollector_EndpointStatus(t *testing.T) {
+	cache := newTestCache(t,
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}},
+			}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.3"}},
+			}},
+		},
+	)
+
+	result, err := NewServiceCollector(cache, nil, CollectorConfig{}).Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	assert.Equal(t, 2, result[0].Status["readyEndpoints"])
+	assert.Equal(t, 1, result[0].Status["notReadyEndpoints"])
+	assert.Equal(t, 1, result[0].Status["ingressPoints"])
+	assert.Equal(t, NetworkMetrics{}, result[0].Network, "services report no traffic of their own")
+}
@@ -2,7 +2,11 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
 )
 
 // ResourceMetrics represents collected metrics for a Kubernetes resource
@@ -13,6 +17,11 @@ type ResourceMetrics struct {
 	Kind      string            `json:"kind"`
 	Labels    map[string]string `json:"labels"`
 
+	// Top-level controller of a pod, such as a Deployment, CronJob or Argo
+	// Rollout, resolved through its owner references
+	OwnerKind string `json:"owner_kind,omitempty"`
+	OwnerName string `json:"owner_name,omitempty"`
+
 	// Collection metadata
 	CollectedAt time.Time `json:"collected_at"`
 
@@ -30,6 +39,9 @@ type ResourceMetrics struct {
 
 	// Status information
 	Status map[string]interface{} `json:"status,omitempty"`
+
+	// CustomMetrics holds the values of custom metric queries by metric name
+	CustomMetrics map[string]float64 `json:"custom_metrics,omitempty"`
 }
 
 // CPUMetrics represents CPU usage metrics
@@ -39,6 +51,21 @@ type CPUMetrics struct {
 	RequestMilliCores int64   `json:"requestMilliCores"`
 	LimitMilliCores   int64   `json:"limitMilliCores"`
 	ThrottlingSeconds float64 `json:"throttlingSeconds"`
+
+	// Usage statistics over the collection interval. Only Prometheus
+	// provides them.
+	UsageAvgNanoCores int64 `json:"usageAvgNanoCores,omitempty"`
+	UsageP50NanoCores int64 `json:"usageP50NanoCores,omitempty"`
+	UsageP95NanoCores int64 `json:"usageP95NanoCores,omitempty"`
+	UsageMaxNanoCores int64 `json:"usageMaxNanoCores,omitempty"`
+}
+
+// setStats sets the usage statistics from core values
+func (c *CPUMetrics) setStats(stats metrics.UsageStats) {
+	c.UsageAvgNanoCores = int64(stats.Avg * 1e9)
+	c.UsageP50NanoCores = int64(stats.P50 * 1e9)
+	c.UsageP95NanoCores = int64(stats.P95 * 1e9)
+	c.UsageMaxNanoCores = int64(stats.Max * 1e9)
 }
 
 // MemoryMetrics represents memory usage metrics
@@ -49,6 +76,21 @@ type MemoryMetrics struct {
 	RSSBytes        int64 `json:"rssBytes"`
 	PageFaults      int64 `json:"pageFaults"`
 	MajorPageFaults int64 `json:"majorPageFaults"`
+
+	// Usage statistics over the collection interval. Only Prometheus
+	// provides them.
+	UsageAvgBytes int64 `json:"usageAvgBytes,omitempty"`
+	UsageP50Bytes int64 `json:"usageP50Bytes,omitempty"`
+	UsageP95Bytes int64 `json:"usageP95Bytes,omitempty"`
+	UsageMaxBytes int64 `json:"usageMaxBytes,omitempty"`
+}
+
+// setStats sets the usage statistics from byte values
+func (m *MemoryMetrics) setStats(stats metrics.UsageStats) {
+	m.UsageAvgBytes = int64(stats.Avg)
+	m.UsageP50Bytes = int64(stats.P50)
+	m.UsageP95Bytes = int64(stats.P95)
+	m.UsageMaxBytes = int64(stats.Max)
 }
 
 // StorageMetrics represents storage usage metrics
@@ -58,9 +100,20 @@ type StorageMetrics struct {
 	Available     int64  `json:"available"`
 	PVCName       string `json:"pvcName,omitempty"`
 	DiskPressure  bool   `json:"diskPressure"`
+
+	// Inode counts of the filesystem of a mounted volume
+	Inodes     int64 `json:"inodes,omitempty"`
+	InodesUsed int64 `json:"inodesUsed,omitempty"`
+	InodesFree int64 `json:"inodesFree,omitempty"`
+
+	// UsageUnknown is set for bound volumes without stats, whose usage and
+	// available bytes are zero because they are not known
+	UsageUnknown bool `json:"usageUnknown,omitempty"`
 }
 
-// NetworkMetrics represents network usage metrics
+// NetworkMetrics represents the network traffic, read from Prometheus or
+// the kubelet, of pods and nodes. The counters are the traffic during the
+// collection interval.
 type NetworkMetrics struct {
 	RxBytes   int64 `json:"rxBytes"`
 	TxBytes   int64 `json:"txBytes"`
@@ -70,6 +123,27 @@ type NetworkMetrics struct {
 	TxErrors  int64 `json:"txErrors"`
 	RxDropped int64 `json:"rxDropped"`
 	TxDropped int64 `json:"txDropped"`
+
+	// Average bandwidth over the collection interval
+	RxBytesPerSecond float64 `json:"rxBytesPerSecond,omitempty"`
+	TxBytesPerSecond float64 `json:"txBytesPerSecond,omitempty"`
+}
+
+// newNetworkMetrics converts per-second rates to the traffic of an interval
+func newNetworkMetrics(rates metrics.NetworkRates, interval time.Duration) NetworkMetrics {
+	seconds := interval.Seconds()
+	return NetworkMetrics{
+		RxBytes:          int64(rates.RxBytes * seconds),
+		TxBytes:          int64(rates.TxBytes * seconds),
+		RxPackets:        int64(rates.RxPackets * seconds),
+		TxPackets:        int64(rates.TxPackets * seconds),
+		RxErrors:         int64(rates.RxErrors * seconds),
+		TxErrors:         int64(rates.TxErrors * seconds),
+		RxDropped:        int64(rates.RxDropped * seconds),
+		TxDropped:        int64(rates.TxDropped * seconds),
+		RxBytesPerSecond: rates.RxBytes,
+		TxBytesPerSecond: rates.TxBytes,
+	}
 }
 
 // CostMetrics represents cost-related metrics
@@ -77,11 +151,21 @@ type CostMetrics struct {
 	CPUCost     float64 `json:"cpuCost"`
 	MemoryCost  float64 `json:"memoryCost"`
 	StorageCost float64 `json:"storageCost"`
+
+	// NetworkCost of pods and nodes prices all their transmitted traffic, an
+	// upper bound of their egress cost left out of TotalCost
 	NetworkCost float64 `json:"networkCost"`
 	TotalCost   float64 `json:"totalCost"`
 	Currency    string  `json:"currency"`
 }
 
+// Metrics sources a usage sample can come from
+const (
+	SourcePrometheus    = "prometheus"
+	SourceMetricsServer = "metrics-server"
+	SourceKubelet       = "kubelet"
+)
+
 // ContainerMetrics represents container metrics
 type ContainerMetrics struct {
 	Name     string        `json:"name"`
@@ -90,6 +174,15 @@ type ContainerMetrics struct {
 	Ready    bool          `json:"ready"`
 	Restarts int32         `json:"restarts"`
 	State    string        `json:"state"`
+
+	// Source is the metrics source the usage was read from, empty when no
+	// source had a sample for the container
+	Source string `json:"source,omitempty"`
+
+	// Filesystem usage of the container's writable layer and logs. Only the
+	// kubelet provides them.
+	Rootfs *StorageMetrics `json:"rootfs,omitempty"`
+	Logs   *StorageMetrics `json:"logs,omitempty"`
 }
 
 // Collector interface defines methods that must be implemented by resource collectors
@@ -104,6 +197,23 @@ type Collector interface {
 	Description() string
 }
 
+// DegradedError is returned along with the metrics of a run that left out
+// part of its data, such as the pods of an unreachable node. The metrics are
+// still delivered and the run counts as a success.
+type DegradedError struct {
+	Err error
+}
+
+func (e *DegradedError) Error() string { return e.Err.Error() }
+
+func (e *DegradedError) Unwrap() error { return e.Err }
+
+// IsDegraded reports whether err is a *DegradedError
+func IsDegraded(err error) bool {
+	var degraded *DegradedError
+	return errors.As(err, &degraded)
+}
+
 // CollectorConfig represents common configuration for collectors
 type CollectorConfig struct {
 	// CollectionInterval is how often metrics should be collected
@@ -123,4 +233,100 @@ type CollectorConfig struct {
 
 	// MaxConcurrentCollections limits concurrent metric collection
 	MaxConcurrentCollections int
+
+	// CostRates are the prices used to estimate resource costs. Zero rates
+	// fall back to DefaultCostRates.
+	CostRates CostRates
+
+	// CustomMetrics are the PromQL queries run by the custom metrics collector
+	CustomMetrics []CustomMetric
+}
+
+// workloadKinds are the kinds covered by the "Workload" resource type. Pods
+// owned by other top-level controllers are reported under the kind of their
+// controller, which then has to be listed by name.
+var workloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "CronJob", "Job", "Rollout"}
+
+// CollectsKind reports whether resources of the given kind should be
+// collected. An empty ResourceTypes list collects every kind.
+func (c CollectorConfig) CollectsKind(kind string) bool {
+	if len(c.ResourceTypes) == 0 {
+		return true
+	}
+	for _, t := range c.ResourceTypes {
+		if strings.EqualFold(t, kind) {
+			return true
+		}
+		if strings.EqualFold(t, "Workload") && contains(workloadKinds, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// CostRates are the hourly prices used to estimate resource costs
+type CostRates struct {
+	// Currency the rates are expressed in
+	Currency string
+
+	// CPUPerCoreHour is the price of one allocatable core for an hour
+	CPUPerCoreHour float64
+
+	// MemoryPerGBHour is the price of one GiB of allocatable memory for an hour
+	MemoryPerGBHour float64
+
+	// StoragePerGBHour is the price of one GiB of persistent storage for an hour
+	StoragePerGBHour float64
+
+	// StorageClassRates overrides StoragePerGBHour for specific storage classes
+	StorageClassRates map[string]float64
+
+	// NetworkPerGB is the price of one GiB of network traffic
+	NetworkPerGB float64
+}
+
+// DefaultCostRates returns the rates used when no pricing is configured
+func DefaultCostRates() CostRates {
+	return CostRates{
+		Currency:         "USD",
+		CPUPerCoreHour:   0.04,
+		MemoryPerGBHour:  0.01,
+		StoragePerGBHour: 0.04, // Standard HDD cost
+		StorageClassRates: map[string]float64{
+			"premium-ssd":  0.17,
+			"standard-ssd": 0.08,
+		},
+	}
+}
+
+// WithDefaults fills unset rates from DefaultCostRates
+func (r CostRates) WithDefaults() CostRates {
+	defaults := DefaultCostRates()
+	if r.Currency == "" {
+		r.Currency = defaults.Currency
+	}
+	if r.CPUPerCoreHour <= 0 {
+		r.CPUPerCoreHour = defaults.CPUPerCoreHour
+	}
+	if r.MemoryPerGBHour <= 0 {
+		r.MemoryPerGBHour = defaults.MemoryPerGBHour
+	}
+	if r.StoragePerGBHour <= 0 {
+		r.StoragePerGBHour = defaults.StoragePerGBHour
+	}
+	if r.StorageClassRates == nil {
+		r.StorageClassRates = defaults.StorageClassRates
+	}
+	if r.NetworkPerGB <= 0 {
+		r.NetworkPerGB = defaults.NetworkPerGB
+	}
+	return r
+}
+
+// StorageRate returns the hourly price per GiB for a storage class
+func (r CostRates) StorageRate(storageClass string) float64 {
+	if rate, ok := r.StorageClassRates[storageClass]; ok {
+		return rate
+	}
+	return r.StoragePerGBHour
 }
@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorConfig_CollectsKind(t *testing.T) {
+	assert.True(t, CollectorConfig{}.CollectsKind("Pod"))
+
+	config := CollectorConfig{ResourceTypes: []string{"pod", "Workload"}}
+	assert.True(t, config.CollectsKind("Pod"))
+	assert.True(t, config.CollectsKind("StatefulSet"))
+	assert.False(t, config.CollectsKind("Node"))
+}
+
+func TestCostRates_WithDefaults(t *testing.T) {
+	rates := CostRates{CPUPerCoreHour: 0.05}.WithDefaults()
+	assert.Equal(t, "USD", rates.Currency)
+	assert.Equal(t, 0.05, rates.CPUPerCoreHour)
+	assert.Equal(t, 0.01, rates.MemoryPerGBHour)
+	assert.Equal(t, 0.17, rates.StorageRate("premium-ssd"))
+	assert.Equal(t, 0.04, rates.StorageRate("gp3"))
+
+	// An empty class map disables the built-in class rates
+	rates = CostRates{StoragePerGBHour: 0.1, StorageClassRates: map[string]float64{}}.WithDefaults()
+	assert.Equal(t, 0.1, rates.StorageRate("premium-ssd"))
+}
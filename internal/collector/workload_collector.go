@@ -3,40 +3,45 @@ package collector
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type WorkloadCollector struct {
-	kubeClient kubernetes.Interface
-	config     CollectorConfig
+	cache  *Cache
+	pods   *PodIndex
+	config CollectorConfig
 }
 
-func NewWorkloadCollector(kubeClient kubernetes.Interface, config CollectorConfig) *WorkloadCollector {
+// NewWorkloadCollector creates a workload collector. The usage and cost of
+// the pods the pod collector last published to pods are summed by top-level
+// controller and added to the record of each workload. pods may be nil, in
+// which case workloads carry no usage.
+func NewWorkloadCollector(cache *Cache, pods *PodIndex, config CollectorConfig) *WorkloadCollector {
 	return &WorkloadCollector{
-		kubeClient: kubeClient,
-		config:     config,
+		cache:  cache,
+		pods:   pods,
+		config: config,
 	}
 }
 
 func (wc *WorkloadCollector) Name() string { return "workload-collector" }
 
 func (wc *WorkloadCollector) Description() string {
-	return "Collects metrics for Kubernetes workloads (Deployments, StatefulSets, DaemonSets)"
+	return "Collects metrics, usage and cost for Kubernetes workloads (Deployments, StatefulSets, DaemonSets and other pod controllers)"
 }
 
 func (wc *WorkloadCollector) Collect(ctx context.Context) ([]ResourceMetrics, error) {
 	var metrics []ResourceMetrics
+	usage, usageErr := wc.podUsage()
 
 	// Collect Deployments
-	deployments, err := wc.kubeClient.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	deployments, err := wc.cache.Deployments(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployments: %w", err)
 	}
 
-	for _, deploy := range deployments.Items {
+	for _, deploy := range deployments {
 		if wc.isNamespaceExcluded(deploy.Namespace) {
 			continue
 		}
@@ -61,16 +66,17 @@ func (wc *WorkloadCollector) Collect(ctx context.Context) ([]ResourceMetrics, er
 			},
 		}
 
+		usage.apply(&metric)
 		metrics = append(metrics, metric)
 	}
 
 	// Collect StatefulSets
-	statefulsets, err := wc.kubeClient.AppsV1().StatefulSets("").List(ctx, metav1.ListOptions{})
+	statefulsets, err := wc.cache.StatefulSets(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
 	}
 
-	for _, sts := range statefulsets.Items {
+	for _, sts := range statefulsets {
 		if wc.isNamespaceExcluded(sts.Namespace) {
 			continue
 		}
@@ -93,16 +99,17 @@ func (wc *WorkloadCollector) Collect(ctx context.Context) ([]ResourceMetrics, er
 			},
 		}
 
+		usage.apply(&metric)
 		metrics = append(metrics, metric)
 	}
 
 	// Collect DaemonSets
-	daemonsets, err := wc.kubeClient.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	daemonsets, err := wc.cache.DaemonSets(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
 	}
 
-	for _, ds := range daemonsets.Items {
+	for _, ds := range daemonsets {
 		if wc.isNamespaceExcluded(ds.Namespace) {
 			continue
 		}
@@ -126,12 +133,139 @@ func (wc *WorkloadCollector) Collect(ctx context.Context) ([]ResourceMetrics, er
 			},
 		}
 
+		usage.apply(&metric)
 		metrics = append(metrics, metric)
 	}
 
+	// Pods can also belong to controllers with no record of their own, such
+	// as CronJobs, standalone Jobs and Argo Rollouts
+	metrics = append(metrics, usage.remaining()...)
+
+	if usageErr != nil {
+		return metrics, &DegradedError{Err: fmt.Errorf("workload usage is incomplete: %w", usageErr)}
+	}
 	return metrics, nil
 }
 
+// workloadKey identifies the top-level controller of pods
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+// workloadUsage sums the usage and cost of the pods of each workload
+type workloadUsage map[workloadKey]*ResourceMetrics
+
+// podUsage sums the usage and cost of the last pod collection by top-level
+// controller. The error tells why the usage is missing or incomplete.
+func (wc *WorkloadCollector) podUsage() (workloadUsage, error) {
+	usage := make(workloadUsage)
+	if wc.pods == nil {
+		return usage, nil
+	}
+	pods, err := wc.pods.Pods()
+
+	now := time.Now()
+	for _, pod := range pods {
+		if pod.OwnerKind == "" {
+			continue
+		}
+		key := workloadKey{namespace: pod.Namespace, kind: pod.OwnerKind, name: pod.OwnerName}
+		total, ok := usage[key]
+		if !ok {
+			total = &ResourceMetrics{
+				Name:        pod.OwnerName,
+				Namespace:   pod.Namespace,
+				Kind:        pod.OwnerKind,
+				CollectedAt: now,
+				Status:      map[string]interface{}{},
+			}
+			usage[key] = total
+		}
+		addPodUsage(total, pod)
+	}
+	return usage, err
+}
+
+// apply adds the usage of a workload to its record. Each workload is applied
+// once, so that remaining returns the others.
+func (u workloadUsage) apply(metric *ResourceMetrics) {
+	key := workloadKey{namespace: metric.Namespace, kind: metric.Kind, name: metric.Name}
+	total, ok := u[key]
+	if !ok {
+		metric.Status["pods"] = 0
+		return
+	}
+	delete(u, key)
+
+	metric.CPU = total.CPU
+	metric.Memory = total.Memory
+	metric.Network = total.Network
+	metric.Cost = total.Cost
+	metric.Status["pods"] = total.Status["pods"]
+}
+
+// remaining returns the usage of the workloads no record was applied to,
+// ordered by namespace, kind and name
+func (u workloadUsage) remaining() []ResourceMetrics {
+	result := make([]ResourceMetrics, 0, len(u))
+	for _, total := range u {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// addPodUsage adds the container usage, network traffic and cost of a pod to
+// the totals of its workload
+func addPodUsage(total *ResourceMetrics, pod ResourceMetrics) {
+	pods, _ := total.Status["pods"].(int)
+	total.Status["pods"] = pods + 1
+
+	for _, container := range pod.Containers {
+		total.CPU.UsageNanoCores += container.CPU.UsageNanoCores
+		total.CPU.UsageCorePercent += container.CPU.UsageCorePercent
+		total.CPU.RequestMilliCores += container.CPU.RequestMilliCores
+		total.CPU.LimitMilliCores += container.CPU.LimitMilliCores
+		total.CPU.ThrottlingSeconds += container.CPU.ThrottlingSeconds
+		total.CPU.UsageAvgNanoCores += container.CPU.UsageAvgNanoCores
+
+		total.Memory.UsageBytes += container.Memory.UsageBytes
+		total.Memory.RequestBytes += container.Memory.RequestBytes
+		total.Memory.LimitBytes += container.Memory.LimitBytes
+		total.Memory.RSSBytes += container.Memory.RSSBytes
+		total.Memory.PageFaults += container.Memory.PageFaults
+		total.Memory.MajorPageFaults += container.Memory.MajorPageFaults
+		total.Memory.UsageAvgBytes += container.Memory.UsageAvgBytes
+	}
+
+	total.Network.RxBytes += pod.Network.RxBytes
+	total.Network.TxBytes += pod.Network.TxBytes
+	total.Network.RxPackets += pod.Network.RxPackets
+	total.Network.TxPackets += pod.Network.TxPackets
+	total.Network.RxErrors += pod.Network.RxErrors
+	total.Network.TxErrors += pod.Network.TxErrors
+	total.Network.RxDropped += pod.Network.RxDropped
+	total.Network.TxDropped += pod.Network.TxDropped
+	total.Network.RxBytesPerSecond += pod.Network.RxBytesPerSecond
+	total.Network.TxBytesPerSecond += pod.Network.TxBytesPerSecond
+
+	total.Cost.Currency = pod.Cost.Currency
+	total.Cost.CPUCost += pod.Cost.CPUCost
+	total.Cost.MemoryCost += pod.Cost.MemoryCost
+	total.Cost.NetworkCost += pod.Cost.NetworkCost
+	total.Cost.TotalCost += pod.Cost.TotalCost
+}
+
 func (wc *WorkloadCollector) isNamespaceExcluded(namespace string) bool {
 	for _, excluded := range wc.config.ExcludeNamespaces {
 		if namespace == excluded {
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkloadCollector_PodUsage(t *testing.T) {
+	pod := func(meta metav1.ObjectMeta) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: meta,
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+			}}},
+		}
+	}
+	cache := newTestCache(t,
+		pod(controlledBy("web-1", "apps/v1", "ReplicaSet", "web-5d4f")),
+		pod(controlledBy("web-2", "apps/v1", "ReplicaSet", "web-5d4f")),
+		pod(controlledBy("report-1", "batch/v1", "Job", "report-28500")),
+		&appsv1.ReplicaSet{ObjectMeta: controlledBy("web-5d4f", "apps/v1", "Deployment", "web")},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "idle"}},
+		&batchv1.Job{ObjectMeta: controlledBy("report-28500", "batch/v1", "CronJob", "report")},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "report"}},
+	)
+	config := CollectorConfig{}
+	index := NewPodIndex()
+	workloads := NewWorkloadCollector(cache, index, config)
+
+	// Workloads are reported without usage until the pod collector ran
+	result, err := workloads.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+	assert.Len(t, result, 2)
+	assert.Equal(t, 0, result[1].Status["pods"])
+
+	_, err = NewPodCollector(cache, nil, nil, NewOwnerResolver(cache, nil, nil), index, config, false, false).Collect(context.Background())
+	assert.NoError(t, err)
+	result, err = workloads.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	idle, web, report := result[0], result[1], result[2]
+	assert.Equal(t, "idle", idle.Name)
+	assert.Equal(t, 0, idle.Status["pods"])
+	assert.Zero(t, idle.Cost.TotalCost)
+
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, 2, web.Status["pods"])
+	assert.Equal(t, int64(1000), web.CPU.RequestMilliCores)
+	assert.Equal(t, int64(2<<30), web.Memory.RequestBytes)
+	rates := DefaultCostRates()
+	assert.InDelta(t, 1*rates.CPUPerCoreHour, web.Cost.CPUCost, 1e-12, "idle pods are billed for their requests")
+	assert.InDelta(t, 2*rates.MemoryPerGBHour, web.Cost.MemoryCost, 1e-12)
+	assert.InDelta(t, web.Cost.CPUCost+web.Cost.MemoryCost, web.Cost.TotalCost, 1e-12)
+
+	// Controllers with no record of their own get one from their pods
+	assert.Equal(t, "CronJob", report.Kind)
+	assert.Equal(t, "report", report.Name)
+	assert.Equal(t, 1, report.Status["pods"])
+	assert.Equal(t, int64(500), report.CPU.RequestMilliCores)
+
+	// Usage the pod collector could not read is reported by the workloads too
+	index.Publish(nil, &DegradedError{Err: errors.New("Prometheus unreachable")})
+	_, err = workloads.Collect(context.Background())
+	assert.True(t, IsDegraded(err))
+	assert.ErrorContains(t, err, "Prometheus unreachable")
+}
+
+func TestPodCost(t *testing.T) {
+	rates := CostRates{CPUPerCoreHour: 1, MemoryPerGBHour: 1, NetworkPerGB: 1}
+	containers := []ContainerMetrics{
+		{
+			// Usage above the request is billed
+			CPU:    CPUMetrics{UsageNanoCores: 3e9, UsageAvgNanoCores: 2e9, RequestMilliCores: 1000},
+			Memory: MemoryMetrics{UsageBytes: 1 << 30, RequestBytes: 2 << 30},
+		},
+		{CPU: CPUMetrics{RequestMilliCores: 500}},
+	}
+	cost := podCost(containers, NetworkMetrics{TxBytesPerSecond: float64(1<<30) / 3600}, rates)
+	assert.InDelta(t, 2.5, cost.CPUCost, 1e-12, "the average usage takes precedence over the current one")
+	assert.InDelta(t, 2.0, cost.MemoryCost, 1e-12)
+	assert.InDelta(t, 1.0, cost.NetworkCost, 1e-12)
+	assert.InDelta(t, 4.5, cost.TotalCost, 1e-12, "transmitted traffic is an upper bound of the egress cost")
+}
@@ -2,9 +2,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 
 	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
@@ -12,29 +16,72 @@ import (
 	"github.com/hakongo/kubernetes-connector/internal/cluster"
 	"github.com/hakongo/kubernetes-connector/internal/collector"
 	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/hakongo/kubernetes-connector/internal/scheduler"
+	"github.com/hakongo/kubernetes-connector/internal/sink"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
-// ConnectorConfigReconciler reconciles a ConnectorConfig object
+const (
+	// spoolDrainInterval is how often spooled batches are replayed in the background
+	spoolDrainInterval = 30 * time.Second
+
+	// pipelineFinalizer keeps a ConnectorConfig until its pipeline is stopped
+	pipelineFinalizer = "hakongo.com/pipeline"
+
+	// customMetricsCollector is the registry name of the custom metrics collector
+	customMetricsCollector = "custom"
+
+	// kubeletCollector is the registry name of the kubelet stats collector
+	kubeletCollector = "kubelet"
+
+	// podCollector is the registry name of the pod collector
+	podCollector = "pod"
+
+	// statusUpdateInterval is how often the reconciler refreshes the status
+	// and the remote configuration. Collectors run on their own schedule.
+	statusUpdateInterval = time.Minute
+)
+
+// ConnectorConfigReconciler reconciles a ConnectorConfig object. Every
+// ConnectorConfig runs as an independent pipeline.
 type ConnectorConfigReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
-	kubeClient       kubernetes.Interface
-	metricsClient    versioned.Interface
-	prometheusClient *metrics.PrometheusClient
-	apiClient        *api.Client
-	collectors       []collector.Collector
-	contextProvider  *cluster.ContextProvider
+	kubeClient    kubernetes.Interface
+	metricsClient versioned.Interface
+
+	// dynamicClient and restMapper read the custom resources pods can be
+	// owned by, such as Argo Rollouts
+	dynamicClient dynamic.Interface
+	restMapper    meta.RESTMapper
+
+	// registry maps the collector names of the spec to collectors
+	registry *collector.Registry
+
+	// pipelines are keyed by ConnectorConfig name
+	pipelinesMu sync.Mutex
+	pipelines   map[string]*pipeline
 }
 
 func (r *ConnectorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -43,70 +90,96 @@ func (r *ConnectorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	// Fetch the ConnectorConfig instance
 	connConfig := &hakongov1alpha1.ConnectorConfig{}
 	if err := r.Get(ctx, req.NamespacedName, connConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.removePipeline(req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Tear down the pipeline before the ConnectorConfig goes away
+	if !connConfig.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(connConfig, pipelineFinalizer) {
+			logger.Info("Stopping pipeline of deleted ConnectorConfig", "name", req.Name)
+			r.removePipeline(req.Name)
+			controllerutil.RemoveFinalizer(connConfig, pipelineFinalizer)
+			if err := r.Update(ctx, connConfig); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(connConfig, pipelineFinalizer) {
+		if err := r.Update(ctx, connConfig); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
 	logger.Info("Reconciling ConnectorConfig", "name", req.Name)
+	p := r.pipeline(req.Name)
 
 	// Initialize clients if needed
-	if err := r.ensureClients(ctx, connConfig); err != nil {
+	if err := r.ensureClients(ctx, p, connConfig); err != nil {
 		logger.Error(err, "Failed to initialize clients")
+		r.setNotReady(ctx, connConfig, "ClientSetupFailed", err)
 		return ctrl.Result{}, err
 	}
 
-	// Initialize cluster context provider
-	if r.contextProvider == nil {
-		metadata := make(map[string]interface{})
-		for k, v := range connConfig.Spec.ClusterContext.Metadata {
-			metadata[k] = v
-		}
-		r.contextProvider = cluster.NewContextProvider(r.kubeClient, &cluster.Config{
-			ClusterName:  connConfig.Spec.ClusterContext.Name,
-			ProviderName: connConfig.Spec.ClusterContext.Type,
-			Region:       connConfig.Spec.ClusterContext.Region,
-			Zone:         connConfig.Spec.ClusterContext.Zone,
-			Labels:       connConfig.Spec.ClusterContext.Labels,
-			Metadata:     metadata,
-		})
-	}
-
 	// Get cluster context
-	clusterCtx, err := r.contextProvider.GetContext(ctx)
+	clusterCtx, err := p.contextProvider.GetContext(ctx)
 	if err != nil {
 		logger.Error(err, "Failed to get cluster context")
+		r.setNotReady(ctx, connConfig, "ClusterContextFailed", err)
 		return ctrl.Result{}, err
 	}
 
-	// Setup collectors with cluster context
-	if err := r.setupCollectors(ctx, connConfig, clusterCtx); err != nil {
-		logger.Error(err, "Failed to setup collectors")
+	// Fetch the remote cluster configuration, which overrides the spec
+	if err := r.refreshRemoteConfig(ctx, p, connConfig, clusterCtx.Name); err != nil {
+		logger.Error(err, "Failed to refresh remote cluster configuration")
+		r.setNotReady(ctx, connConfig, "InvalidSpec", err)
 		return ctrl.Result{}, err
 	}
 
-	// Start collecting metrics
-	if err := r.collectMetrics(ctx, clusterCtx); err != nil {
-		logger.Error(err, "Failed to collect metrics")
+	// Setup collectors with cluster context and schedule them
+	unknown, err := r.setupCollectors(ctx, p, connConfig, clusterCtx)
+	if err != nil {
+		logger.Error(err, "Failed to setup collectors")
+		r.setNotReady(ctx, connConfig, "CollectorSetupFailed", err)
 		return ctrl.Result{}, err
 	}
+	if len(unknown) > 0 {
+		logger.Info("Ignoring unknown collectors", "collectors", unknown, "known", r.registry.Names())
+	}
+
+	// Probe the metrics sources
+	var sources sourceHealth
+	if connConfig.Spec.Prometheus != nil {
+		sources.prometheusConfigured = true
+		sources.prometheusErr = p.checkPrometheus(ctx)
+	}
+	if p.useMetricsServer {
+		sources.metricsServerConfigured = true
+		sources.metricsServerErr = r.checkMetricsServer(ctx)
+	}
+	if p.useKubelet {
+		sources.kubeletConfigured = true
+		sources.kubeletErr = r.checkKubelet(ctx)
+	}
+
+	r.updateStatus(ctx, p, connConfig, unknown, sources)
 
-	return ctrl.Result{RequeueAfter: time.Minute}, nil
+	return ctrl.Result{RequeueAfter: statusUpdateInterval}, nil
 }
 
-func (r *ConnectorConfigReconciler) setupCollectors(_ context.Context, config *hakongov1alpha1.ConnectorConfig, clusterCtx *cluster.ClusterContext) error {
+// setupCollectors creates the collectors listed in the spec and schedules
+// them. It returns the names in the spec that match no known collector.
+func (r *ConnectorConfigReconciler) setupCollectors(_ context.Context, p *pipeline, config *hakongov1alpha1.ConnectorConfig, clusterCtx *cluster.ClusterContext) ([]string, error) {
 	// Determine which metrics sources to use based on user configuration
 	usePrometheus := false
 	useMetricsServer := false
 
-	// Initialize Prometheus client if configured
-	if config.Spec.Prometheus != nil {
+	// Use Prometheus if configured
+	if p.prometheusClient != nil {
 		usePrometheus = true
-		if r.prometheusClient == nil {
-			var err error
-			r.prometheusClient, err = metrics.NewPrometheusClient(config.Spec.Prometheus.URL)
-			if err != nil {
-				return fmt.Errorf("failed to create Prometheus client: %w", err)
-			}
-		}
 	}
 
 	// Check if Metrics Server is enabled
@@ -118,6 +191,11 @@ func (r *ConnectorConfigReconciler) setupCollectors(_ context.Context, config *h
 	if !usePrometheus && !useMetricsServer {
 		useMetricsServer = true
 	}
+	p.useMetricsServer = useMetricsServer
+
+	// The kubelet stats summary is read in addition to the other sources
+	useKubelet := config.Spec.Kubelet != nil && config.Spec.Kubelet.Enabled
+	p.useKubelet = useKubelet
 
 	// Create base collector config
 	collectorConfig := collector.CollectorConfig{
@@ -138,59 +216,143 @@ func (r *ConnectorConfigReconciler) setupCollectors(_ context.Context, config *h
 		}
 	}
 
-	// Override config from spec if provided
-	if len(config.Spec.Collectors) > 0 {
-		for _, c := range config.Spec.Collectors {
-			if c.Interval > 0 {
-				collectorConfig.CollectionInterval = time.Duration(c.Interval) * time.Second
-			}
-			if c.Labels != nil {
-				// Merge labels
-				for k, v := range c.Labels {
-					collectorConfig.IncludeLabels[k] = v
-				}
+	// Only the collectors listed in the spec run, or all of them when none are listed
+	specs := config.Spec.Collectors
+	if len(specs) == 0 {
+		for _, name := range r.registry.Names() {
+			// Reading every kubelet is opt-in
+			if name == kubeletCollector && !useKubelet {
+				continue
 			}
+			specs = append(specs, hakongov1alpha1.CollectorSpec{Name: name})
 		}
 	}
 
-	// Create collectors
-	r.collectors = []collector.Collector{
-		collector.NewPodCollector(r.kubeClient, r.prometheusClient, collectorConfig, usePrometheus),
-		collector.NewNodeCollector(r.kubeClient, r.metricsClient, r.prometheusClient, collectorConfig, usePrometheus, useMetricsServer),
-		collector.NewPVCollector(r.kubeClient, r.metricsClient, collectorConfig),
-		collector.NewServiceCollector(r.kubeClient, r.metricsClient, collectorConfig),
-		collector.NewNamespaceCollector(r.kubeClient, collectorConfig),
-		collector.NewWorkloadCollector(r.kubeClient, collectorConfig),
-		collector.NewIngressCollector(r.kubeClient, collectorConfig),
-		collector.NewEventCollector(r.kubeClient, collectorConfig),
+	// Apply cost settings from the spec
+	costRates, err := specCostRates(config.Spec.Cost)
+	if err != nil {
+		return nil, err
+	}
+	collectorConfig.CostRates = costRates
+
+	customMetrics, err := customMetrics(config.Spec.CustomMetrics)
+	if err != nil {
+		return nil, err
 	}
+	collectorConfig.CustomMetrics = customMetrics
 
-	return nil
+	// Remote cluster configuration takes precedence over the spec
+	mergeRemoteConfig(&collectorConfig, p.remoteConfig)
+	p.collectorConfig = collectorConfig
+
+	// The cache is only rebuilt when the watched namespaces change. The
+	// previous one stops once the new collectors are scheduled.
+	if p.cache == nil || !slices.Equal(p.cache.WatchedNamespaces(), collectorConfig.IncludeNamespaces) {
+		if stale := p.cache; stale != nil {
+			defer stale.Stop()
+		}
+		p.cache = collector.NewCache(r.kubeClient, collectorConfig.IncludeNamespaces)
+	}
+
+	// Custom metrics run even when the spec lists collectors without them
+	if len(collectorConfig.CustomMetrics) > 0 && !hasCollector(specs, customMetricsCollector) {
+		specs = append(specs, hakongov1alpha1.CollectorSpec{Name: customMetricsCollector})
+	}
+	if useKubelet && !hasCollector(specs, kubeletCollector) {
+		specs = append(specs, hakongov1alpha1.CollectorSpec{Name: kubeletCollector})
+	}
+	// Values of custom metrics removed from the configuration are no longer
+	// attached
+	p.customMetrics.Retain(collectorConfig.CustomMetrics)
+	// Workloads only carry usage while the pod collector publishes it
+	if !hasCollector(specs, podCollector) {
+		p.pods.Reset()
+	}
+
+	deps := collector.Dependencies{
+		KubeClient:       r.kubeClient,
+		Cache:            p.cache,
+		MetricsClient:    r.metricsClient,
+		Owners:           collector.NewOwnerResolver(p.cache, r.dynamicClient, r.restMapper),
+		Pods:             p.pods,
+		PrometheusClient: p.prometheusClient,
+		UsePrometheus:    usePrometheus,
+		UseMetricsServer: useMetricsServer,
+		UseKubelet:       useKubelet,
+		CustomMetricRuns: p.customMetricRuns,
+		CustomMetrics:    p.customMetrics,
+		KubeletCounters:  p.kubeletCounters,
+	}
+	if config.Spec.Kubelet != nil {
+		deps.KubeletMaxConcurrentNodes = int(config.Spec.Kubelet.MaxConcurrentNodes)
+	}
+
+	// Schedule each collector on its own interval. A remote collection
+	// interval overrides the per-collector intervals of the spec.
+	_, remoteInterval := remoteCollectionInterval(p.remoteConfig)
+	logger := ctrl.Log.WithName("collector").WithValues("connectorconfig", p.name)
+	s := p.sink
+	var unknown []string
+	jobs := make([]scheduler.Job, 0, len(specs))
+	for _, spec := range specs {
+		interval := collectorConfig.CollectionInterval
+		if spec.Interval > 0 && !remoteInterval {
+			interval = time.Duration(spec.Interval) * time.Second
+		}
+
+		// Usage statistics cover the interval of the collector
+		jobConfig := collectorConfig
+		jobConfig.CollectionInterval = interval
+		c, err := r.registry.New(spec.Name, deps, jobConfig)
+		if err != nil {
+			unknown = append(unknown, spec.Name)
+			continue
+		}
+		if spec.Name != customMetricsCollector {
+			c = collector.WithCustomMetrics(c, p.customMetrics)
+		}
+		// Spec labels apply to this collector's output only
+		c = collector.WithLabels(c, spec.Labels)
+		c = collector.WithBreaker(c, p.breaker(spec.Name))
+
+		jobLogger := logger.WithValues("collector", spec.Name)
+		jobs = append(jobs, scheduler.Job{
+			Name:     spec.Name,
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				return p.runCollector(log.IntoContext(ctx, jobLogger), c, collectorConfig, s, clusterCtx)
+			},
+		})
+	}
+	p.scheduler.Schedule(jobs...)
+
+	return unknown, nil
 }
 
-func (r *ConnectorConfigReconciler) collectMetrics(ctx context.Context, clusterCtx *cluster.ClusterContext) error {
+// checkPrometheus logs whether Prometheus is reachable and returns sample
+// data. It returns the error of the connectivity query.
+func (p *pipeline) checkPrometheus(ctx context.Context) error {
 	logger := log.FromContext(ctx)
-	var allMetrics []collector.ResourceMetrics
 
-	// Log Prometheus client status with detailed information
-	if r.prometheusClient != nil {
-		logger.Info("Prometheus client is configured", "url", r.prometheusClient.GetBaseURL())
+	if p.prometheusClient != nil {
+		logger.Info("Prometheus client is configured", "url", p.prometheusClient.GetBaseURL())
 		
 		// Test Prometheus connectivity
 		testCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 		
 		// Simple query to check if Prometheus is responding
-		testResult, err := r.prometheusClient.Query(testCtx, "up", time.Now())
+		testResult, err := p.prometheusClient.Query(testCtx, "up", time.Now())
 		if err != nil {
-			logger.Error(err, "Failed to connect to Prometheus server", "url", r.prometheusClient.GetBaseURL())
+			logger.Error(err, "Failed to connect to Prometheus server", "url", p.prometheusClient.GetBaseURL())
+			return err
 		} else {
-			logger.Info("Successfully connected to Prometheus server", "url", r.prometheusClient.GetBaseURL(), "result", testResult.String())
+			logger.Info("Successfully connected to Prometheus server", "url", p.prometheusClient.GetBaseURL(), "result", testResult.String())
 			
 			// Get some sample metrics to verify Prometheus data collection
 			// Query CPU usage for pods
 			cpuQuery := "sum(rate(container_cpu_usage_seconds_total{container!='POD',container!=''}[5m])) by (pod, namespace)"
-			cpuResult, cpuErr := r.prometheusClient.Query(testCtx, cpuQuery, time.Now())
+			cpuResult, cpuErr := p.prometheusClient.Query(testCtx, cpuQuery, time.Now())
 			if cpuErr != nil {
 				logger.Error(cpuErr, "Failed to query CPU metrics from Prometheus", "query", cpuQuery)
 			} else {
@@ -199,7 +361,7 @@ func (r *ConnectorConfigReconciler) collectMetrics(ctx context.Context, clusterC
 			
 			// Query memory usage for pods
 			memQuery := "sum(container_memory_working_set_bytes{container!='POD',container!=''}) by (pod, namespace)"
-			memResult, memErr := r.prometheusClient.Query(testCtx, memQuery, time.Now())
+			memResult, memErr := p.prometheusClient.Query(testCtx, memQuery, time.Now())
 			if memErr != nil {
 				logger.Error(memErr, "Failed to query memory metrics from Prometheus", "query", memQuery)
 			} else {
@@ -209,168 +371,192 @@ func (r *ConnectorConfigReconciler) collectMetrics(ctx context.Context, clusterC
 	} else {
 		logger.Info("Prometheus client is not configured, metrics will be limited")
 	}
+	return nil
+}
 
-	// Separate metrics for events and other resources
-	var eventMetrics []collector.ResourceMetrics
-	var regularMetrics []collector.ResourceMetrics
+// checkMetricsServer verifies that the Metrics Server API serves node metrics
+func (r *ConnectorConfigReconciler) checkMetricsServer(ctx context.Context) error {
+	testCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	// Collect metrics from all collectors with detailed logging
-	for _, c := range r.collectors {
-		logger.Info("Starting metrics collection", "collector", c.Name(), "description", c.Description())
-		
-		// Measure collection time
-		startTime := time.Now()
-		metrics, err := c.Collect(ctx)
-		collectionDuration := time.Since(startTime)
-		
-		if err != nil {
-			logger.Error(err, "Failed to collect metrics", "collector", c.Name(), "duration_ms", collectionDuration.Milliseconds())
-			return fmt.Errorf("failed to collect metrics from %s: %w", c.Name(), err)
+	if _, err := r.metricsClient.MetricsV1beta1().NodeMetricses().List(testCtx, metav1.ListOptions{Limit: 1}); err != nil {
+		log.FromContext(ctx).Error(err, "Metrics Server is not available")
+		return err
+	}
+	return nil
+}
+
+// checkKubelet verifies that the stats summary of a node can be read
+// through the API server node proxy
+func (r *ConnectorConfigReconciler) checkKubelet(ctx context.Context) error {
+	testCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	nodes, err := r.kubeClient.CoreV1().Nodes().List(testCtx, metav1.ListOptions{Limit: 1})
+	if err == nil && len(nodes.Items) > 0 {
+		_, err = metrics.NewKubeletClient(r.kubeClient).GetSummary(testCtx, nodes.Items[0].Name)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Kubelet stats summary is not available")
+		return err
+	}
+	return nil
+}
+
+// runCollector runs one collector and writes its metrics to the sinks. Each
+// run is its own collection cycle.
+func (p *pipeline) runCollector(ctx context.Context, c collector.Collector, collectorConfig collector.CollectorConfig, s sink.Sink, clusterCtx *cluster.ClusterContext) error {
+	logger := log.FromContext(ctx)
+
+	// The cycle ID keys every chunk sent in this cycle so that retries and
+	// spool replays are deduplicated by the API
+	cycleID := api.NewCycleID()
+
+	logger.Info("Starting metrics collection", "collector", c.Name(), "description", c.Description())
+	
+	// Measure collection time
+	startTime := time.Now()
+	metrics, err := c.Collect(ctx)
+	collectionDuration := time.Since(startTime)
+	
+	if errors.Is(err, collector.ErrCircuitOpen) {
+		logger.Info("Skipping collector while its circuit breaker is open", "collector", c.Name(), "reason", err.Error())
+		return scheduler.ErrSkipped
+	}
+	if collector.IsDegraded(err) {
+		// The metrics collected are still delivered, the breaker reports
+		// what they miss in the collector status
+		logger.Info("Collected partial metrics", "collector", c.Name(), "reason", err.Error())
+		err = nil
+	}
+	if err != nil {
+		logger.Error(err, "Failed to collect metrics",
+			"collector", c.Name(),
+			"error_class", collector.ClassifyError(err),
+			"duration_ms", collectionDuration.Milliseconds())
+		return fmt.Errorf("failed to collect metrics from %s: %w", c.Name(), err)
+	}
+	
+	logger.Info("Successfully collected metrics", 
+		"collector", c.Name(), 
+		"count", len(metrics), 
+		"duration_ms", collectionDuration.Milliseconds())
+	
+	// Log a sample of metrics for debugging
+	if len(metrics) > 0 {
+		sampleSize := 2 // Increase sample size for better visibility
+		if len(metrics) < sampleSize {
+			sampleSize = len(metrics)
 		}
 		
-		logger.Info("Successfully collected metrics", 
-			"collector", c.Name(), 
-			"count", len(metrics), 
-			"duration_ms", collectionDuration.Milliseconds())
+		logger.Info("Metrics sample", "collector", c.Name(), "sample_size", sampleSize, "total", len(metrics))
 		
-		// Log a sample of metrics for debugging
-		if len(metrics) > 0 {
-			sampleSize := 2 // Increase sample size for better visibility
-			if len(metrics) < sampleSize {
-				sampleSize = len(metrics)
-			}
+		for i := 0; i < sampleSize; i++ {
+			// Log basic resource info
+			logger.Info("Resource metrics", 
+				"collector", c.Name(),
+				"resource", metrics[i].Kind+"/"+metrics[i].Name,
+				"namespace", metrics[i].Namespace,
+				"labels", fmt.Sprintf("%v", metrics[i].Labels),
+				"collected_at", metrics[i].CollectedAt.Format(time.RFC3339))
 			
-			logger.Info("Metrics sample", "collector", c.Name(), "sample_size", sampleSize, "total", len(metrics))
-			
-			for i := 0; i < sampleSize; i++ {
-				// Log basic resource info
-				logger.Info("Resource metrics", 
-					"collector", c.Name(),
+			// Log resource usage metrics if not an event
+			if metrics[i].Kind != "Event" {
+				logger.Info("Resource usage", 
 					"resource", metrics[i].Kind+"/"+metrics[i].Name,
-					"namespace", metrics[i].Namespace,
-					"labels", fmt.Sprintf("%v", metrics[i].Labels),
-					"collected_at", metrics[i].CollectedAt.Format(time.RFC3339))
-				
-				// Log resource usage metrics if not an event
-				if metrics[i].Kind != "Event" {
-					logger.Info("Resource usage", 
+					"cpu_usage_cores", float64(metrics[i].CPU.UsageNanoCores) / 1e9,
+					"cpu_usage_percent", metrics[i].CPU.UsageCorePercent,
+					"memory_usage_mb", float64(metrics[i].Memory.UsageBytes) / (1024 * 1024),
+					"memory_request_mb", float64(metrics[i].Memory.RequestBytes) / (1024 * 1024),
+					"memory_limit_mb", float64(metrics[i].Memory.LimitBytes) / (1024 * 1024))
+			} else {
+				// Log event-specific information
+				if metrics[i].Status != nil {
+					logger.Info("Event details",
 						"resource", metrics[i].Kind+"/"+metrics[i].Name,
-						"cpu_usage_cores", float64(metrics[i].CPU.UsageNanoCores) / 1e9,
-						"cpu_usage_percent", metrics[i].CPU.UsageCorePercent,
-						"memory_usage_mb", float64(metrics[i].Memory.UsageBytes) / (1024 * 1024),
-						"memory_request_mb", float64(metrics[i].Memory.RequestBytes) / (1024 * 1024),
-						"memory_limit_mb", float64(metrics[i].Memory.LimitBytes) / (1024 * 1024))
-				} else {
-					// Log event-specific information
-					if metrics[i].Status != nil {
-						logger.Info("Event details",
-							"resource", metrics[i].Kind+"/"+metrics[i].Name,
-							"type", metrics[i].Status["type"],
-							"reason", metrics[i].Status["reason"],
-							"count", metrics[i].Status["count"],
-							"severity", metrics[i].Status["severity"])
-					}
+						"type", metrics[i].Status["type"],
+						"reason", metrics[i].Status["reason"],
+						"count", metrics[i].Status["count"],
+						"severity", metrics[i].Status["severity"])
+				}
+			}
+			
+			// Log container metrics if available
+			if len(metrics[i].Containers) > 0 {
+				containerSampleSize := 2
+				if len(metrics[i].Containers) < containerSampleSize {
+					containerSampleSize = len(metrics[i].Containers)
 				}
 				
-				// Log container metrics if available
-				if len(metrics[i].Containers) > 0 {
-					containerSampleSize := 2
-					if len(metrics[i].Containers) < containerSampleSize {
-						containerSampleSize = len(metrics[i].Containers)
-					}
-					
-					logger.Info("Container metrics", 
-						"resource", metrics[i].Kind+"/"+metrics[i].Name, 
-						"container_count", len(metrics[i].Containers),
-						"sample_size", containerSampleSize)
-					
-					for j := 0; j < containerSampleSize; j++ {
-						logger.Info("Container details", 
-							"resource", metrics[i].Kind+"/"+metrics[i].Name,
-							"container", metrics[i].Containers[j].Name,
-							"cpu_usage_cores", float64(metrics[i].Containers[j].CPU.UsageNanoCores) / 1e9,
-							"memory_usage_mb", float64(metrics[i].Containers[j].Memory.UsageBytes) / (1024 * 1024),
-							"ready", metrics[i].Containers[j].Ready,
-							"restarts", metrics[i].Containers[j].Restarts,
-							"state", metrics[i].Containers[j].State)
-					}
+				logger.Info("Container metrics", 
+					"resource", metrics[i].Kind+"/"+metrics[i].Name, 
+					"container_count", len(metrics[i].Containers),
+					"sample_size", containerSampleSize)
+				
+				for j := 0; j < containerSampleSize; j++ {
+					logger.Info("Container details", 
+						"resource", metrics[i].Kind+"/"+metrics[i].Name,
+						"container", metrics[i].Containers[j].Name,
+						"cpu_usage_cores", float64(metrics[i].Containers[j].CPU.UsageNanoCores) / 1e9,
+						"memory_usage_mb", float64(metrics[i].Containers[j].Memory.UsageBytes) / (1024 * 1024),
+						"ready", metrics[i].Containers[j].Ready,
+						"restarts", metrics[i].Containers[j].Restarts,
+						"state", metrics[i].Containers[j].State)
 				}
 			}
 		}
-		
-		// Separate event metrics from other resource metrics
-		for _, metric := range metrics {
-			if metric.Kind == "Event" {
-				eventMetrics = append(eventMetrics, metric)
-			} else {
-				regularMetrics = append(regularMetrics, metric)
-			}
-		}
-		
-		// Add all metrics to the combined list for backward compatibility
-		allMetrics = append(allMetrics, metrics...)
-	}
-
-	// Log the total number of metrics collected with summary
-	logger.Info("Total metrics collected", 
-		"count", len(allMetrics), 
-		"regular_metrics", len(regularMetrics),
-		"event_metrics", len(eventMetrics),
-		"collector_count", len(r.collectors),
-		"cluster_name", clusterCtx.Name,
-		"timestamp", time.Now().Format(time.RFC3339))
-
-	// Send regular metrics to API with detailed logging
-	if len(regularMetrics) > 0 {
-		logger.Info("Sending regular metrics to HakonGo API", 
-			"count", len(regularMetrics), 
-			"api_url", r.apiClient.GetBaseURL())
-		
-		startTime := time.Now()
-		if err := r.apiClient.SendMetrics(ctx, regularMetrics); err != nil {
-			// Log the error but don't fail the reconciliation
-			logger.Error(err, "Failed to send regular metrics to HakonGo API", 
-				"duration_ms", time.Since(startTime).Milliseconds())
-			// Continue processing even if sending metrics fails
-		} else {
-			logger.Info("Successfully sent regular metrics to HakonGo API", 
-				"count", len(regularMetrics), 
-				"duration_ms", time.Since(startTime).Milliseconds())
+	}
+	
+	// Keep metrics of the configured resource types
+	var collected []collector.ResourceMetrics
+	for _, metric := range metrics {
+		if collectorConfig.CollectsKind(metric.Kind) {
+			collected = append(collected, metric)
 		}
 	}
+	if len(collected) == 0 {
+		return nil
+	}
 
-	// Send event metrics to API with detailed logging
-	if len(eventMetrics) > 0 {
-		logger.Info("Sending event metrics to HakonGo API", 
-			"count", len(eventMetrics), 
-			"api_url", r.apiClient.GetBaseURL())
-		
-		// Create cluster context map for event metrics
-		clusterContextMap := map[string]interface{}{
-			"name":     clusterCtx.Name,
-			"provider": clusterCtx.Provider.Name,
-			"region":   clusterCtx.Provider.Region,
-			"zone":     clusterCtx.Provider.Zone,
-			"labels":   clusterCtx.Labels,
-		}
-		
-		startTime := time.Now()
-		if err := r.apiClient.SendEventMetrics(ctx, clusterCtx.Name, clusterContextMap, eventMetrics); err != nil {
-			// Log the error but don't fail the reconciliation
-			logger.Error(err, "Failed to send event metrics to HakonGo API", 
-				"duration_ms", time.Since(startTime).Milliseconds())
-			// Continue processing even if sending metrics fails
-		} else {
-			logger.Info("Successfully sent event metrics to HakonGo API", 
-				"count", len(eventMetrics), 
-				"duration_ms", time.Since(startTime).Milliseconds())
-		}
+	// Write metrics to all configured sinks
+	logger.Info("Writing metrics to sinks", 
+		"collector", c.Name(),
+		"count", len(collected), 
+		"sinks", s.Name())
+	
+	startTime = time.Now()
+	err = s.Write(ctx, &sink.Batch{
+		ClusterID: clusterCtx.Name,
+		CycleID:   cycleID,
+		Context:   clusterCtx,
+		Metrics:   collected,
+	})
+	if err != nil {
+		logger.Error(err, "Failed to write metrics to sinks",
+			"collector", c.Name(),
+			"partial", api.IsPartialFailure(err),
+			"duration_ms", time.Since(startTime).Milliseconds())
+		return fmt.Errorf("failed to write metrics to sinks: %w", err)
 	}
 
+	logger.Info("Successfully wrote metrics to sinks", 
+		"collector", c.Name(),
+		"count", len(collected), 
+		"duration_ms", time.Since(startTime).Milliseconds())
+
+	p.mu.Lock()
+	p.lastUpload = time.Now()
+	p.mu.Unlock()
+
 	return nil
 }
 
-func (r *ConnectorConfigReconciler) ensureClients(ctx context.Context, config *hakongov1alpha1.ConnectorConfig) error {
+// ensureClients creates the Kubernetes clients once and rebuilds the other
+// clients whenever the spec they are configured with or one of the referenced
+// secrets changes. The new clients are built first and swapped in together,
+// so a failed rebuild keeps the previous clients. Collector runs in progress
+// finish with the clients they started with.
+func (r *ConnectorConfigReconciler) ensureClients(ctx context.Context, p *pipeline, config *hakongov1alpha1.ConnectorConfig) error {
 	// Initialize Kubernetes clients if needed
 	if r.kubeClient == nil {
 		cfg, err := rest.InClusterConfig()
@@ -396,14 +582,27 @@ func (r *ConnectorConfigReconciler) ensureClients(ctx context.Context, config *h
 		if err != nil {
 			return fmt.Errorf("failed to create metrics client: %w", err)
 		}
+
+		r.dynamicClient, err = dynamic.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+		// The discovery cache is refreshed when an owner kind is not found,
+		// so custom resources installed later are resolved too
+		r.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(r.kubeClient.Discovery()))
+	}
+
+	hash, err := r.clientsHash(ctx, config)
+	if err != nil {
+		return err
+	}
+	if hash == p.clientsConfigHash {
+		return nil
 	}
 
 	// Get API key from secret
 	var apiKeySecret corev1.Secret
-	namespace := config.Namespace
-	if namespace == "" {
-		namespace = "default" // Use default namespace if not specified
-	}
+	namespace := secretNamespace(config)
 	if err := r.Get(ctx, types.NamespacedName{
 		Name:      config.Spec.HakonGo.APIKey.Name,
 		Namespace: namespace,
@@ -417,21 +616,392 @@ func (r *ConnectorConfigReconciler) ensureClients(ctx context.Context, config *h
 			config.Spec.HakonGo.APIKey.Name, config.Spec.HakonGo.APIKey.Key)
 	}
 
-	// Initialize API client if needed
-	if r.apiClient == nil {
-		r.apiClient = api.NewClient(api.ClientConfig{
-			BaseURL: config.Spec.HakonGo.BaseURL,
-			APIKey:  apiKey,
-			Timeout: 30 * time.Second,
+	compression := api.Compression(config.Spec.HakonGo.Compression)
+	clientConfig := api.ClientConfig{
+		BaseURL:            config.Spec.HakonGo.BaseURL,
+		APIKey:             apiKey,
+		Timeout:            30 * time.Second,
+		MaxRetries:         3,
+		RetryWaitDuration:  time.Second,
+		CompressionEnabled: compression != "" && compression != api.CompressionNone,
+		Compression:        compression,
+		MaxBatchItems:      int(config.Spec.HakonGo.MaxBatchItems),
+	}
+	if config.Spec.HakonGo.MaxBatchSize != nil {
+		clientConfig.MaxBatchBytes = int(config.Spec.HakonGo.MaxBatchSize.Value())
+	}
+	apiClient := api.NewClient(clientConfig)
+
+	// The outbound spool keeps its batches when only the client or the
+	// limits change
+	var spool *api.Spool
+	if spec := config.Spec.HakonGo.Spool; spec != nil {
+		if owner := r.spoolOwner(spec.Path, p); owner != "" {
+			return fmt.Errorf("spool path %s is already used by ConnectorConfig %s", spec.Path, owner)
+		}
+		current := p.getSpool()
+		if current != nil && current.Dir() == spec.Path {
+			spoolConfig, err := spoolConfig(spec)
+			if err != nil {
+				return err
+			}
+			if err := current.Update(apiClient, spoolConfig); err != nil {
+				return fmt.Errorf("failed to update spool: %w", err)
+			}
+			spool = current
+		} else {
+			spool, err = newSpool(apiClient, spec)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Initialize Prometheus client if configured
+	var prometheusClient *metrics.PrometheusClient
+	if config.Spec.Prometheus != nil {
+		auth, err := r.prometheusAuth(ctx, config.Spec.Prometheus, namespace)
+		if err != nil {
+			return err
+		}
+		queryTimeout := metrics.DefaultQueryTimeout
+		if s := config.Spec.Prometheus.QueryTimeout; s != "" {
+			queryTimeout, err = time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid Prometheus queryTimeout %q: %w", s, err)
+			}
+		}
+		prometheusClient, err = metrics.NewPrometheusClientWithConfig(metrics.ClientConfig{
+			URL:                  config.Spec.Prometheus.URL,
+			Auth:                 auth,
+			QueryTimeout:         queryTimeout,
+			MaxConcurrentQueries: int(config.Spec.Prometheus.MaxConcurrentQueries),
+			Queries:              prometheusQueries(config.Spec.Prometheus.Queries),
 		})
+		if err != nil {
+			return fmt.Errorf("failed to create Prometheus client: %w", err)
+		}
+	}
+
+	// Initialize the sinks metrics are written to
+	s, err := r.newSink(ctx, config, namespace, apiClient, spool)
+	if err != nil {
+		return err
+	}
+
+	// Initialize cluster context provider
+	metadata := make(map[string]interface{})
+	for k, v := range config.Spec.ClusterContext.Metadata {
+		metadata[k] = v
+	}
+	contextProvider := cluster.NewContextProvider(r.kubeClient, &cluster.Config{
+		ClusterName:  config.Spec.ClusterContext.Name,
+		ProviderName: config.Spec.ClusterContext.Type,
+		Region:       config.Spec.ClusterContext.Region,
+		Zone:         config.Spec.ClusterContext.Zone,
+		Labels:       config.Spec.ClusterContext.Labels,
+		Metadata:     metadata,
+	})
+
+	if p.clientsConfigHash != "" {
+		log.FromContext(ctx).Info("Configuration changed, rebuilt clients")
 	}
 
+	p.apiClient = apiClient
+	p.prometheusClient = prometheusClient
+	p.sink = s
+	p.contextProvider = contextProvider
+	p.mu.Lock()
+	p.spool = spool
+	p.mu.Unlock()
+	p.clientsConfigHash = hash
+
 	return nil
 }
 
+// newSink builds the sinks described by the spec. Without any sinks
+// configured metrics are sent to the HakonGo API only.
+func (r *ConnectorConfigReconciler) newSink(ctx context.Context, config *hakongov1alpha1.ConnectorConfig, namespace string, apiClient *api.Client, spool *api.Spool) (sink.Sink, error) {
+	if len(config.Spec.Sinks) == 0 {
+		return sink.NewHakonGoSink(sink.TypeHakonGo, apiClient, spool), nil
+	}
+
+	sinks := make([]sink.Sink, 0, len(config.Spec.Sinks))
+	for _, spec := range config.Spec.Sinks {
+		var s sink.Sink
+		switch spec.Type {
+		case sink.TypeHakonGo:
+			s = sink.NewHakonGoSink(spec.Name, apiClient, spool)
+		case sink.TypeFile:
+			if spec.File == nil {
+				return nil, fmt.Errorf("sink %s: file configuration is required", spec.Name)
+			}
+			s = sink.NewFileSink(spec.Name, spec.File.Path)
+		case sink.TypeStdout:
+			s = sink.NewStdoutSink(spec.Name)
+		case sink.TypeWebhook:
+			if spec.Webhook == nil {
+				return nil, fmt.Errorf("sink %s: webhook configuration is required", spec.Name)
+			}
+			webhookConfig, err := r.webhookConfig(ctx, spec.Webhook, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("sink %s: %w", spec.Name, err)
+			}
+			s = sink.NewWebhookSink(spec.Name, webhookConfig)
+		default:
+			return nil, fmt.Errorf("sink %s: unsupported type %q", spec.Name, spec.Type)
+		}
+		sinks = append(sinks, sink.NewKindFilter(s, spec.Kinds))
+	}
+
+	return sink.NewMulti(sinks...), nil
+}
+
+// webhookConfig resolves the webhook sink spec, reading the bearer token from its secret
+func (r *ConnectorConfigReconciler) webhookConfig(ctx context.Context, spec *hakongov1alpha1.WebhookSinkConfig, namespace string) (sink.WebhookConfig, error) {
+	webhookConfig := sink.WebhookConfig{
+		URL:     spec.URL,
+		Headers: make(map[string]string, len(spec.Headers)+1),
+		Timeout: 30 * time.Second,
+	}
+	for k, v := range spec.Headers {
+		webhookConfig.Headers[k] = v
+	}
+
+	if spec.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.Timeout)
+		if err != nil {
+			return sink.WebhookConfig{}, fmt.Errorf("invalid webhook timeout %q: %w", spec.Timeout, err)
+		}
+		webhookConfig.Timeout = timeout
+	}
+
+	if spec.BearerToken != nil {
+		token, err := r.secretValue(ctx, namespace, spec.BearerToken)
+		if err != nil {
+			return sink.WebhookConfig{}, fmt.Errorf("failed to read webhook bearer token: %w", err)
+		}
+		webhookConfig.Headers["Authorization"] = "Bearer " + string(token)
+	}
+
+	return webhookConfig, nil
+}
+
+// prometheusQueries converts the query customizations of the spec
+func prometheusQueries(spec *hakongov1alpha1.PrometheusQueries) metrics.QueryConfig {
+	if spec == nil {
+		return metrics.QueryConfig{}
+	}
+	return metrics.QueryConfig{
+		Preset:       spec.Preset,
+		Templates:    spec.Templates,
+		LabelRenames: spec.LabelRenames,
+		Window:       spec.Window,
+		Selector:     spec.Selector,
+	}
+}
+
+// specCostRates returns the cost rates set in the spec. Rates left unset
+// fall back to the defaults.
+func specCostRates(spec *hakongov1alpha1.CostConfig) (collector.CostRates, error) {
+	var rates collector.CostRates
+	if spec == nil {
+		return rates, nil
+	}
+	rates.Currency = spec.Currency
+	if spec.NetworkPerGB != "" {
+		rate, err := strconv.ParseFloat(spec.NetworkPerGB, 64)
+		if err != nil {
+			return rates, fmt.Errorf("invalid network cost rate %q: %w", spec.NetworkPerGB, err)
+		}
+		rates.NetworkPerGB = rate
+	}
+	return rates, nil
+}
+
+// customMetrics converts the custom metrics of the spec
+func customMetrics(specs []hakongov1alpha1.CustomMetricSpec) ([]collector.CustomMetric, error) {
+	result := make([]collector.CustomMetric, 0, len(specs))
+	for _, spec := range specs {
+		var interval time.Duration
+		if spec.Interval != "" {
+			var err error
+			interval, err = time.ParseDuration(spec.Interval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval for custom metric %s: %w", spec.Name, err)
+			}
+		}
+		result = append(result, collector.CustomMetric{
+			Name:       spec.Name,
+			Query:      spec.Query,
+			Resource:   spec.Resource,
+			Labels:     spec.Labels,
+			Aggregator: spec.Aggregator,
+			Interval:   interval,
+		})
+	}
+	return result, nil
+}
+
+// hasCollector reports whether the named collector is in specs
+func hasCollector(specs []hakongov1alpha1.CollectorSpec, name string) bool {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// prometheusAuth resolves the credentials and TLS settings of the Prometheus
+// spec from their secrets
+func (r *ConnectorConfigReconciler) prometheusAuth(ctx context.Context, spec *hakongov1alpha1.PrometheusConfig, namespace string) (metrics.AuthConfig, error) {
+	var auth metrics.AuthConfig
+
+	// read resolves an optional secret reference
+	read := func(sel *corev1.SecretKeySelector, what string) ([]byte, error) {
+		if sel == nil {
+			return nil, nil
+		}
+		value, err := r.secretValue(ctx, namespace, sel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Prometheus %s: %w", what, err)
+		}
+		return value, nil
+	}
+
+	if spec.BasicAuth != nil {
+		username, err := read(spec.BasicAuth.Username, "basic auth username")
+		if err != nil {
+			return auth, err
+		}
+		password, err := read(spec.BasicAuth.Password, "basic auth password")
+		if err != nil {
+			return auth, err
+		}
+		auth.Username = string(username)
+		auth.Password = string(password)
+	}
+
+	token, err := read(spec.BearerToken, "bearer token")
+	if err != nil {
+		return auth, err
+	}
+	auth.BearerToken = string(token)
+
+	if tlsConfig := spec.TLSConfig; tlsConfig != nil {
+		if auth.CA, err = read(tlsConfig.CA, "CA certificate"); err != nil {
+			return auth, err
+		}
+		if auth.Cert, err = read(tlsConfig.Cert, "client certificate"); err != nil {
+			return auth, err
+		}
+		if auth.Key, err = read(tlsConfig.Key, "client key"); err != nil {
+			return auth, err
+		}
+		auth.InsecureSkipVerify = tlsConfig.InsecureSkipVerify
+	}
+
+	return auth, nil
+}
+
+// newSpool creates the outbound spool described by the spec
+func newSpool(apiClient *api.Client, spec *hakongov1alpha1.SpoolConfig) (*api.Spool, error) {
+	spoolConfig, err := spoolConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	spool, err := api.NewSpool(apiClient, spoolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool: %w", err)
+	}
+	return spool, nil
+}
+
+// spoolConfig converts the spool spec
+func spoolConfig(spec *hakongov1alpha1.SpoolConfig) (api.SpoolConfig, error) {
+	spoolConfig := api.SpoolConfig{
+		Dir: spec.Path,
+	}
+	if spec.MaxSize != nil {
+		spoolConfig.MaxBytes = spec.MaxSize.Value()
+	}
+	if spec.MaxAge != "" {
+		maxAge, err := time.ParseDuration(spec.MaxAge)
+		if err != nil {
+			return api.SpoolConfig{}, fmt.Errorf("invalid spool maxAge %q: %w", spec.MaxAge, err)
+		}
+		spoolConfig.MaxAge = maxAge
+	}
+	return spoolConfig, nil
+}
+
+// spoolOwner returns the name of the pipeline other than p whose spool is in
+// dir, if any. Two pipelines sharing a spool would deliver each other's batches.
+func (r *ConnectorConfigReconciler) spoolOwner(dir string, p *pipeline) string {
+	for _, other := range r.listPipelines() {
+		if other == p {
+			continue
+		}
+		if spool := other.getSpool(); spool != nil && spool.Dir() == dir {
+			return other.name
+		}
+	}
+	return ""
+}
+
+// drainSpool replays spooled batches in the background until the manager stops
+func (r *ConnectorConfigReconciler) drainSpool(ctx context.Context) error {
+	logger := ctrl.Log.WithName("spool")
+	ticker := time.NewTicker(spoolDrainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, p := range r.listPipelines() {
+			spool := p.getSpool()
+			if spool == nil {
+				continue
+			}
+
+			sent, err := spool.Drain(ctx)
+			if err != nil {
+				logger.Error(err, "Failed to drain spool", "connectorconfig", p.name, "sent", sent)
+				continue
+			}
+			if sent > 0 {
+				logger.Info("Replayed spooled batches", "connectorconfig", p.name, "count", sent)
+			}
+		}
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConnectorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.registry = collector.DefaultRegistry()
+	if err := mgr.Add(manager.RunnableFunc(r.stopPipelines)); err != nil {
+		return fmt.Errorf("failed to add pipeline shutdown: %w", err)
+	}
+
+	if err := mgr.Add(manager.RunnableFunc(r.drainSpool)); err != nil {
+		return fmt.Errorf("failed to add spool drainer: %w", err)
+	}
+
+	// Index the configs by the secrets they reference so that rotating a
+	// secret rebuilds the clients of the configs using it
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &hakongov1alpha1.ConnectorConfig{}, secretRefIndex, indexSecretRefs); err != nil {
+		return fmt.Errorf("failed to index secret references: %w", err)
+	}
+
+	// Status updates must not trigger a reconcile, which would collect again
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&hakongov1alpha1.ConnectorConfig{}).
+		For(&hakongov1alpha1.ConnectorConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.configsForSecret)).
 		Complete(r)
 }
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/cluster"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/hakongo/kubernetes-connector/internal/scheduler"
+	"github.com/hakongo/kubernetes-connector/internal/sink"
+)
+
+// pipeline collects and delivers the metrics of one ConnectorConfig. Each
+// ConnectorConfig owns its pipeline, so configs never share clients,
+// collectors, schedules or sinks.
+type pipeline struct {
+	// name is the name of the ConnectorConfig
+	name string
+
+	apiClient        *api.Client
+	prometheusClient *metrics.PrometheusClient
+	contextProvider  *cluster.ContextProvider
+	sink             sink.Sink
+
+	// clientsConfigHash is the clientsHash the current clients were built from
+	clientsConfigHash string
+
+	// scheduler runs each collector on its own interval until stop is called
+	scheduler *scheduler.Scheduler
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	// breakers track the health of each collector by name. They outlive the
+	// collectors, which are recreated on every reconcile.
+	breakers map[string]*collector.Breaker
+
+	// customMetricRuns remembers when each custom metric query last ran
+	customMetricRuns *collector.RunTracker
+
+	// customMetrics holds the custom metric values attached to the records
+	// of the other collectors
+	customMetrics *collector.CustomMetricIndex
+
+	// kubeletCounters holds the last samples of the kubelet stats counters
+	kubeletCounters *collector.CounterTracker
+
+	// pods holds the last pod collection, which the workload collector sums
+	pods *collector.PodIndex
+
+	// cache holds the Kubernetes objects the collectors read. Its informers
+	// keep watching across reconciles.
+	cache *collector.Cache
+
+	// collectorConfig is the effective configuration of the current collectors
+	collectorConfig collector.CollectorConfig
+
+	// remoteConfig is the cluster configuration last fetched from the API
+	remoteConfig        *api.ClusterConfig
+	remoteConfigFetched time.Time
+
+	// useMetricsServer is set when collectors read from the Metrics Server
+	useMetricsServer bool
+
+	// useKubelet is set when the kubelet stats summary is collected
+	useKubelet bool
+
+	// mu guards spool, which is shared with the background drainer, and
+	// lastUpload, which is written by the collector runs
+	mu         sync.Mutex
+	spool      *api.Spool
+	lastUpload time.Time
+}
+
+// newPipeline creates a pipeline and starts its scheduler
+func newPipeline(name string) *pipeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pipeline{
+		name:      name,
+		scheduler: scheduler.New(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		breakers:  make(map[string]*collector.Breaker),
+
+		customMetricRuns: collector.NewRunTracker(),
+		customMetrics:    collector.NewCustomMetricIndex(),
+		kubeletCounters:  collector.NewCounterTracker(),
+		pods:             collector.NewPodIndex(),
+	}
+	go func() {
+		defer close(p.done)
+		p.scheduler.Start(ctx)
+	}()
+	return p
+}
+
+// stop cancels the collectors and waits for runs in progress to finish
+func (p *pipeline) stop() {
+	p.cancel()
+	<-p.done
+	if p.cache != nil {
+		p.cache.Stop()
+	}
+}
+
+// breaker returns the circuit breaker of the named collector
+func (p *pipeline) breaker(name string) *collector.Breaker {
+	b, ok := p.breakers[name]
+	if !ok {
+		b = collector.NewBreaker(collector.DefaultBreakerConfig())
+		p.breakers[name] = b
+	}
+	return b
+}
+
+func (p *pipeline) getSpool() *api.Spool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.spool
+}
+
+// pipeline returns the pipeline of the named ConnectorConfig, creating it on
+// first use
+func (r *ConnectorConfigReconciler) pipeline(name string) *pipeline {
+	r.pipelinesMu.Lock()
+	defer r.pipelinesMu.Unlock()
+
+	if r.pipelines == nil {
+		r.pipelines = make(map[string]*pipeline)
+	}
+	p, ok := r.pipelines[name]
+	if !ok {
+		p = newPipeline(name)
+		r.pipelines[name] = p
+	}
+	return p
+}
+
+// removePipeline stops the pipeline of the named ConnectorConfig and forgets
+// it. It does nothing if the config has no pipeline.
+func (r *ConnectorConfigReconciler) removePipeline(name string) {
+	r.pipelinesMu.Lock()
+	p, ok := r.pipelines[name]
+	delete(r.pipelines, name)
+	r.pipelinesMu.Unlock()
+
+	if ok {
+		p.stop()
+	}
+}
+
+// listPipelines returns the current pipelines
+func (r *ConnectorConfigReconciler) listPipelines() []*pipeline {
+	r.pipelinesMu.Lock()
+	defer r.pipelinesMu.Unlock()
+
+	pipelines := make([]*pipeline, 0, len(r.pipelines))
+	for _, p := range r.pipelines {
+		pipelines = append(pipelines, p)
+	}
+	return pipelines
+}
+
+// stopPipelines stops all pipelines when the manager shuts down
+func (r *ConnectorConfigReconciler) stopPipelines(ctx context.Context) error {
+	<-ctx.Done()
+
+	r.pipelinesMu.Lock()
+	pipelines := r.pipelines
+	r.pipelines = nil
+	r.pipelinesMu.Unlock()
+
+	for _, p := range pipelines {
+		p.stop()
+	}
+	return nil
+}
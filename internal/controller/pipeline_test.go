@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestPipelinesAreIndependent(t *testing.T) {
+	r := &ConnectorConfigReconciler{}
+
+	tenantA := r.pipeline("tenant-a")
+	tenantB := r.pipeline("tenant-b")
+	assert.NotSame(t, tenantA, tenantB)
+	assert.Same(t, tenantA, r.pipeline("tenant-a"))
+	assert.NotSame(t, tenantA.breaker("pod"), tenantB.breaker("pod"))
+
+	var runsA, runsB atomic.Int32
+	tenantA.scheduler.Schedule(scheduler.Job{Name: "pod", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+		runsA.Add(1)
+		return nil
+	}})
+	tenantB.scheduler.Schedule(scheduler.Job{Name: "pod", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+		runsB.Add(1)
+		return nil
+	}})
+	assert.Eventually(t, func() bool { return runsA.Load() > 0 && runsB.Load() > 0 }, time.Second, 5*time.Millisecond)
+
+	// Removing one pipeline stops its collectors only
+	r.removePipeline("tenant-a")
+	stopped := runsA.Load()
+	before := runsB.Load()
+	assert.Eventually(t, func() bool { return runsB.Load() > before+1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, stopped, runsA.Load())
+	assert.Len(t, r.listPipelines(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, r.stopPipelines(ctx))
+	assert.Empty(t, r.listPipelines())
+}
+
+func TestSpoolOwner(t *testing.T) {
+	r := &ConnectorConfigReconciler{}
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.stopPipelines(ctx)
+	}()
+
+	dir := t.TempDir()
+	spool, err := api.NewSpool(api.NewClient(api.ClientConfig{}), api.SpoolConfig{Dir: dir})
+	assert.NoError(t, err)
+
+	tenantA := r.pipeline("tenant-a")
+	tenantA.spool = spool
+	tenantB := r.pipeline("tenant-b")
+
+	assert.Equal(t, "tenant-a", r.spoolOwner(dir, tenantB))
+	assert.Empty(t, r.spoolOwner(dir, tenantA))
+	assert.Empty(t, r.spoolOwner(t.TempDir(), tenantB))
+}
+
+func TestReconcileDeletedConnectorConfig(t *testing.T) {
+	config := testConnectorConfig()
+	config.Finalizers = []string{pipelineFinalizer}
+	config.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	r := newFakeReconciler(t, config)
+	r.pipeline(config.Name)
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name}})
+	assert.NoError(t, err)
+	assert.Empty(t, r.listPipelines())
+
+	// Without its finalizer the ConnectorConfig is gone
+	err = r.Get(context.Background(), types.NamespacedName{Name: config.Name}, &hakongov1alpha1.ConnectorConfig{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
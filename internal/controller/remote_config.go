@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultConfigPollInterval is used when the spec does not set a poll interval
+	defaultConfigPollInterval = 5 * time.Minute
+
+	// minRemoteCollectionInterval guards against remote intervals that would
+	// make the reconciler spin
+	minRemoteCollectionInterval = 10 * time.Second
+
+	// hoursPerMonth converts the monthly prices of the remote costing
+	// configuration to the hourly rates used by the collectors
+	hoursPerMonth = 730
+
+	configSourceSpec   = "spec"
+	configSourceRemote = "remote"
+)
+
+// refreshRemoteConfig fetches the cluster configuration from the API once the
+// poll interval has elapsed. Fetch failures are logged and the last fetched
+// configuration stays in effect until the next attempt.
+func (r *ConnectorConfigReconciler) refreshRemoteConfig(ctx context.Context, p *pipeline, config *hakongov1alpha1.ConnectorConfig, clusterID string) error {
+	logger := log.FromContext(ctx)
+
+	interval := defaultConfigPollInterval
+	if s := config.Spec.HakonGo.ConfigPollInterval; s != "" {
+		var err error
+		interval, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid configPollInterval %q: %w", s, err)
+		}
+	}
+
+	if interval <= 0 {
+		p.remoteConfig = nil
+		return nil
+	}
+	if !p.remoteConfigFetched.IsZero() && time.Since(p.remoteConfigFetched) < interval {
+		return nil
+	}
+
+	remote, err := p.apiClient.GetClusterConfig(ctx, clusterID)
+	if err != nil {
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// The cluster has no remote configuration, the spec applies as is
+			p.remoteConfig = nil
+			p.remoteConfigFetched = time.Now()
+			return nil
+		}
+		logger.Error(err, "Failed to fetch remote cluster configuration, keeping the last known configuration",
+			"cluster_id", clusterID)
+		return nil
+	}
+
+	p.remoteConfig = remote
+	p.remoteConfigFetched = time.Now()
+	logger.Info("Fetched remote cluster configuration",
+		"cluster_id", clusterID,
+		"collection_interval", remote.CollectionInterval.String(),
+		"resource_types", remote.ResourceTypes)
+
+	return nil
+}
+
+// mergeRemoteConfig applies the settings of the remote cluster configuration
+// on top of the collector config built from the spec. Unset remote fields
+// leave the spec values in place.
+func mergeRemoteConfig(collectorConfig *collector.CollectorConfig, remote *api.ClusterConfig) {
+	if remote == nil {
+		return
+	}
+
+	if interval, ok := remoteCollectionInterval(remote); ok {
+		collectorConfig.CollectionInterval = interval
+	}
+	if len(remote.IncludeNamespaces) > 0 {
+		collectorConfig.IncludeNamespaces = remote.IncludeNamespaces
+	}
+	// An explicit empty list clears the default exclusions
+	if remote.ExcludeNamespaces != nil {
+		collectorConfig.ExcludeNamespaces = remote.ExcludeNamespaces
+	}
+	if len(remote.ResourceTypes) > 0 {
+		collectorConfig.ResourceTypes = remote.ResourceTypes
+	}
+	// Remote custom metrics replace the spec metrics of the same name
+	for _, m := range remote.CustomMetrics {
+		custom := collector.CustomMetric{
+			Name:       m.Name,
+			Query:      m.Query,
+			Resource:   m.Resource,
+			Labels:     m.Labels,
+			Aggregator: m.Aggregator,
+			Interval:   m.Interval,
+		}
+		replaced := false
+		for i := range collectorConfig.CustomMetrics {
+			if collectorConfig.CustomMetrics[i].Name == m.Name {
+				collectorConfig.CustomMetrics[i] = custom
+				replaced = true
+			}
+		}
+		if !replaced {
+			collectorConfig.CustomMetrics = append(collectorConfig.CustomMetrics, custom)
+		}
+	}
+
+	costing := remote.CostingConfiguration
+	rates := &collectorConfig.CostRates
+	if costing.Currency != "" {
+		rates.Currency = costing.Currency
+	}
+	if costing.CPUCostPerCore > 0 {
+		rates.CPUPerCoreHour = costing.CPUCostPerCore / hoursPerMonth
+	}
+	if costing.MemoryCostPerGB > 0 {
+		rates.MemoryPerGBHour = costing.MemoryCostPerGB / hoursPerMonth
+	}
+	if costing.StorageCostPerGB > 0 {
+		// A remote storage price applies to every storage class
+		rates.StoragePerGBHour = costing.StorageCostPerGB / hoursPerMonth
+		rates.StorageClassRates = map[string]float64{}
+	}
+	if costing.NetworkCostPerGB > 0 {
+		rates.NetworkPerGB = costing.NetworkCostPerGB
+	}
+}
+
+// remoteCollectionInterval returns the collection interval set by the remote
+// cluster configuration, if any
+func remoteCollectionInterval(remote *api.ClusterConfig) (time.Duration, bool) {
+	if remote == nil || remote.CollectionInterval < minRemoteCollectionInterval {
+		return 0, false
+	}
+	return remote.CollectionInterval, true
+}
+
+// effectiveConfigStatus describes the collector config for the ConnectorConfig status
+func effectiveConfigStatus(collectorConfig collector.CollectorConfig, remote *api.ClusterConfig, fetched time.Time) *hakongov1alpha1.EffectiveConfig {
+	rates := collectorConfig.CostRates.WithDefaults()
+	status := &hakongov1alpha1.EffectiveConfig{
+		Source:             configSourceSpec,
+		CollectionInterval: collectorConfig.CollectionInterval.String(),
+		IncludeNamespaces:  collectorConfig.IncludeNamespaces,
+		ExcludeNamespaces:  collectorConfig.ExcludeNamespaces,
+		ResourceTypes:      collectorConfig.ResourceTypes,
+		Cost: &hakongov1alpha1.EffectiveCostRates{
+			Currency:         rates.Currency,
+			CPUPerCoreHour:   formatRate(rates.CPUPerCoreHour),
+			MemoryPerGBHour:  formatRate(rates.MemoryPerGBHour),
+			StoragePerGBHour: formatRate(rates.StoragePerGBHour),
+			NetworkPerGB:     formatRate(rates.NetworkPerGB),
+		},
+	}
+	if remote != nil {
+		status.Source = configSourceRemote
+	}
+	if !fetched.IsZero() {
+		status.RemoteConfigTime = &metav1.Time{Time: fetched}
+	}
+	return status
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
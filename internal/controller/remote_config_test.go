@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeRemoteConfig(t *testing.T) {
+	collectorConfig := collector.CollectorConfig{
+		CollectionInterval: time.Minute,
+		ExcludeNamespaces:  []string{"kube-system"},
+		CostRates:          collector.CostRates{Currency: "EUR"},
+	}
+
+	mergeRemoteConfig(&collectorConfig, &api.ClusterConfig{
+		CollectionInterval: 5 * time.Minute,
+		IncludeNamespaces:  []string{"team-a"},
+		ResourceTypes:      []string{"Pod", "Node"},
+		CostingConfiguration: api.CostingConfiguration{
+			CPUCostPerCore:   73,
+			StorageCostPerGB: 0.73,
+		},
+	})
+
+	assert.Equal(t, 5*time.Minute, collectorConfig.CollectionInterval)
+	assert.Equal(t, []string{"team-a"}, collectorConfig.IncludeNamespaces)
+	assert.Equal(t, []string{"kube-system"}, collectorConfig.ExcludeNamespaces, "unset remote fields keep the spec value")
+	assert.Equal(t, []string{"Pod", "Node"}, collectorConfig.ResourceTypes)
+	assert.Equal(t, "EUR", collectorConfig.CostRates.Currency)
+
+	rates := collectorConfig.CostRates.WithDefaults()
+	assert.InDelta(t, 0.1, rates.CPUPerCoreHour, 1e-9)
+	assert.InDelta(t, 0.01, rates.MemoryPerGBHour, 1e-9, "unset remote rates keep the default")
+	assert.InDelta(t, 0.001, rates.StorageRate("premium-ssd"), 1e-9)
+}
+
+func TestMergeRemoteConfig_IgnoresTinyInterval(t *testing.T) {
+	collectorConfig := collector.CollectorConfig{CollectionInterval: time.Minute}
+	mergeRemoteConfig(&collectorConfig, &api.ClusterConfig{CollectionInterval: 60})
+	assert.Equal(t, time.Minute, collectorConfig.CollectionInterval)
+}
+
+func TestMergeRemoteConfig_CustomMetrics(t *testing.T) {
+	collectorConfig := collector.CollectorConfig{
+		CustomMetrics: []collector.CustomMetric{
+			{Name: "requests", Query: "sum(rate(http_requests_total[5m])) by (namespace, pod)"},
+			{Name: "queue_depth", Query: "sum(queue_depth) by (namespace)"},
+		},
+	}
+
+	mergeRemoteConfig(&collectorConfig, &api.ClusterConfig{
+		CustomMetrics: []api.CustomMetricConfig{
+			{Name: "requests", Query: "sum(rate(http_requests_total[1m])) by (namespace, pod)", Interval: 5 * time.Minute},
+			{Name: "gpu_util", Query: "avg(DCGM_FI_DEV_GPU_UTIL) by (node)", Resource: collector.CustomResourceNode, Aggregator: "avg"},
+		},
+	})
+
+	assert.Len(t, collectorConfig.CustomMetrics, 3)
+	assert.Equal(t, "sum(rate(http_requests_total[1m])) by (namespace, pod)", collectorConfig.CustomMetrics[0].Query, "remote metrics replace spec metrics of the same name")
+	assert.Equal(t, 5*time.Minute, collectorConfig.CustomMetrics[0].Interval)
+	assert.Equal(t, "queue_depth", collectorConfig.CustomMetrics[1].Name)
+	assert.Equal(t, collector.CustomResourceNode, collectorConfig.CustomMetrics[2].Resource)
+}
+
+func TestEffectiveConfigStatus(t *testing.T) {
+	collectorConfig := collector.CollectorConfig{CollectionInterval: time.Minute}
+
+	status := effectiveConfigStatus(collectorConfig, nil, time.Time{})
+	assert.Equal(t, configSourceSpec, status.Source)
+	assert.Equal(t, "1m0s", status.CollectionInterval)
+	assert.Nil(t, status.RemoteConfigTime)
+	assert.Equal(t, "0.04", status.Cost.CPUPerCoreHour)
+
+	status = effectiveConfigStatus(collectorConfig, &api.ClusterConfig{}, time.Now())
+	assert.Equal(t, configSourceRemote, status.Source)
+	assert.NotNil(t, status.RemoteConfigTime)
+}
+
+func TestSpecCostRates(t *testing.T) {
+	rates, err := specCostRates(&hakongov1alpha1.CostConfig{Currency: "EUR", NetworkPerGB: "0.09"})
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", rates.Currency)
+	assert.InDelta(t, 0.09, rates.NetworkPerGB, 1e-9)
+
+	// Remote rates still take precedence over the spec
+	collectorConfig := collector.CollectorConfig{CostRates: rates}
+	mergeRemoteConfig(&collectorConfig, &api.ClusterConfig{
+		CostingConfiguration: api.CostingConfiguration{NetworkCostPerGB: 0.02},
+	})
+	assert.InDelta(t, 0.02, collectorConfig.CostRates.NetworkPerGB, 1e-9)
+
+	rates, err = specCostRates(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, rates.WithDefaults().NetworkPerGB)
+
+	_, err = specCostRates(&hakongov1alpha1.CostConfig{NetworkPerGB: "cheap"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// secretRefIndex indexes ConnectorConfigs by the secrets they reference, as
+// "namespace/name", so that a secret change can be mapped back to them
+const secretRefIndex = "spec.secretRefs"
+
+// secretNamespace returns the namespace the secrets of a config are read from
+func secretNamespace(config *hakongov1alpha1.ConnectorConfig) string {
+	if config.Namespace == "" {
+		return "default"
+	}
+	return config.Namespace
+}
+
+// secretValue reads the key of a secret in the given namespace. Missing and
+// empty keys are errors.
+func (r *ConnectorConfigReconciler) secretValue(ctx context.Context, namespace string, sel *corev1.SecretKeySelector) ([]byte, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: sel.Name, Namespace: namespace}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, sel.Name, err)
+	}
+	value := secret.Data[sel.Key]
+	if len(value) == 0 {
+		return nil, fmt.Errorf("key %s not found in secret %s/%s", sel.Key, namespace, sel.Name)
+	}
+	return value, nil
+}
+
+// secretRefs returns the names of all secrets referenced by the spec, sorted
+// and without duplicates
+func secretRefs(config *hakongov1alpha1.ConnectorConfig) []string {
+	seen := make(map[string]bool)
+	add := func(sel *corev1.SecretKeySelector) {
+		if sel != nil && sel.Name != "" {
+			seen[sel.Name] = true
+		}
+	}
+
+	add(&config.Spec.HakonGo.APIKey)
+	if prom := config.Spec.Prometheus; prom != nil {
+		if prom.BasicAuth != nil {
+			add(prom.BasicAuth.Username)
+			add(prom.BasicAuth.Password)
+		}
+		add(prom.BearerToken)
+		if prom.TLSConfig != nil {
+			add(prom.TLSConfig.CA)
+			add(prom.TLSConfig.Cert)
+			add(prom.TLSConfig.Key)
+		}
+	}
+	for _, spec := range config.Spec.Sinks {
+		if spec.Webhook != nil {
+			add(spec.Webhook.BearerToken)
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// indexSecretRefs is the field indexer of secretRefIndex
+func indexSecretRefs(obj client.Object) []string {
+	config, ok := obj.(*hakongov1alpha1.ConnectorConfig)
+	if !ok {
+		return nil
+	}
+	namespace := secretNamespace(config)
+	names := secretRefs(config)
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, namespace+"/"+name)
+	}
+	return keys
+}
+
+// configsForSecret maps a secret to the ConnectorConfigs referencing it
+func (r *ConnectorConfigReconciler) configsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var configs hakongov1alpha1.ConnectorConfigList
+	if err := r.List(ctx, &configs, client.MatchingFields{
+		secretRefIndex: obj.GetNamespace() + "/" + obj.GetName(),
+	}); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(configs.Items))
+	for _, config := range configs.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+		})
+	}
+	return requests
+}
+
+// clientsHash fingerprints everything the clients are built from: the parts
+// of the spec they are configured with and the versions of the referenced
+// secrets. The clients are rebuilt whenever it changes.
+func (r *ConnectorConfigReconciler) clientsHash(ctx context.Context, config *hakongov1alpha1.ConnectorConfig) (string, error) {
+	namespace := secretNamespace(config)
+	versions := make(map[string]string)
+	for _, name := range secretRefs(config) {
+		var secret corev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &secret); err != nil {
+			return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+		}
+		versions[name] = secret.ResourceVersion
+	}
+
+	data, err := json.Marshal(struct {
+		HakonGo        hakongov1alpha1.HakonGoConfig        `json:"hakongo"`
+		Prometheus     *hakongov1alpha1.PrometheusConfig    `json:"prometheus,omitempty"`
+		ClusterContext hakongov1alpha1.ClusterContextConfig `json:"clusterContext"`
+		Sinks          []hakongov1alpha1.SinkSpec           `json:"sinks,omitempty"`
+		Secrets        map[string]string                    `json:"secrets"`
+	}{
+		HakonGo:        config.Spec.HakonGo,
+		Prometheus:     config.Spec.Prometheus,
+		ClusterContext: config.Spec.ClusterContext,
+		Sinks:          config.Spec.Sinks,
+		Secrets:        versions,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal client configuration: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testConnectorConfig() *hakongov1alpha1.ConnectorConfig {
+	return &hakongov1alpha1.ConnectorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "connector"},
+		Spec: hakongov1alpha1.ConnectorConfigSpec{
+			HakonGo: hakongov1alpha1.HakonGoConfig{
+				BaseURL: "https://api.hakongo.com",
+				APIKey:  corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "api-key"}, Key: "key"},
+			},
+			Sinks: []hakongov1alpha1.SinkSpec{
+				{
+					Name: "audit",
+					Type: "webhook",
+					Webhook: &hakongov1alpha1.WebhookSinkConfig{
+						URL:         "https://example.com/hook",
+						BearerToken: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "webhook-token"}, Key: "token"},
+					},
+				},
+				{
+					Name: "audit-2",
+					Type: "webhook",
+					Webhook: &hakongov1alpha1.WebhookSinkConfig{
+						URL:         "https://example.com/hook-2",
+						BearerToken: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "api-key"}, Key: "token"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newFakeReconciler(t *testing.T, objs ...client.Object) *ConnectorConfigReconciler {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, hakongov1alpha1.AddToScheme(scheme))
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(&hakongov1alpha1.ConnectorConfig{}, secretRefIndex, indexSecretRefs).
+		Build()
+	return &ConnectorConfigReconciler{Client: c, Scheme: scheme}
+}
+
+func TestSecretRefs(t *testing.T) {
+	config := testConnectorConfig()
+	assert.Equal(t, []string{"api-key", "webhook-token"}, secretRefs(config))
+	assert.Equal(t, []string{"default/api-key", "default/webhook-token"}, indexSecretRefs(config))
+}
+
+func TestConfigsForSecret(t *testing.T) {
+	r := newFakeReconciler(t, testConnectorConfig())
+
+	requests := r.configsForSecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-token", Namespace: "default"},
+	})
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "connector", requests[0].Name)
+
+	requests = r.configsForSecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-token", Namespace: "other"},
+	})
+	assert.Empty(t, requests)
+}
+
+func TestClientsHash(t *testing.T) {
+	ctx := context.Background()
+	apiKey := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-key", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("first"), "token": []byte("token")},
+	}
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-token", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("token")},
+	}
+	config := testConnectorConfig()
+	r := newFakeReconciler(t, apiKey, token)
+
+	hash, err := r.clientsHash(ctx, config)
+	assert.NoError(t, err)
+
+	// Unchanged inputs give the same hash
+	again, err := r.clientsHash(ctx, config)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	// Rotating a secret changes it
+	apiKey.Data["key"] = []byte("second")
+	assert.NoError(t, r.Update(ctx, apiKey))
+	rotated, err := r.clientsHash(ctx, config)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, rotated)
+
+	// So does changing the spec
+	config.Spec.HakonGo.BaseURL = "https://eu.api.hakongo.com"
+	changed, err := r.clientsHash(ctx, config)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rotated, changed)
+
+	// Settings the clients do not use leave it alone
+	config.Spec.Collectors = []hakongov1alpha1.CollectorSpec{{Name: "pod"}}
+	unrelated, err := r.clientsHash(ctx, config)
+	assert.NoError(t, err)
+	assert.Equal(t, changed, unrelated)
+
+	// A missing secret is an error
+	assert.NoError(t, r.Delete(ctx, token))
+	_, err = r.clientsHash(ctx, config)
+	assert.Error(t, err)
+}
+
+func TestPrometheusAuth(t *testing.T) {
+	ctx := context.Background()
+	creds := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus", Namespace: "default"},
+		Data: map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("secret"),
+			"ca.crt":   []byte("ca"),
+		},
+	}
+	r := newFakeReconciler(t, creds)
+	selector := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "prometheus"}, Key: key}
+	}
+
+	auth, err := r.prometheusAuth(ctx, &hakongov1alpha1.PrometheusConfig{
+		URL: "https://prometheus:9090",
+		BasicAuth: &hakongov1alpha1.BasicAuthConfig{
+			Username: selector("username"),
+			Password: selector("password"),
+		},
+		TLSConfig: &hakongov1alpha1.TLSConfig{
+			CA:                 selector("ca.crt"),
+			InsecureSkipVerify: true,
+		},
+	}, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", auth.Username)
+	assert.Equal(t, "secret", auth.Password)
+	assert.Equal(t, []byte("ca"), auth.CA)
+	assert.Empty(t, auth.Cert)
+	assert.True(t, auth.InsecureSkipVerify)
+
+	_, err = r.prometheusAuth(ctx, &hakongov1alpha1.PrometheusConfig{
+		URL:         "https://prometheus:9090",
+		BearerToken: selector("token"),
+	}, "default")
+	assert.Error(t, err)
+}
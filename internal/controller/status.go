@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/hakongo/kubernetes-connector/internal/metrics"
+	"github.com/hakongo/kubernetes-connector/internal/scheduler"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// sourceHealth is the outcome of probing the metrics sources
+type sourceHealth struct {
+	prometheusConfigured    bool
+	prometheusErr           error
+	metricsServerConfigured bool
+	metricsServerErr        error
+	kubeletConfigured       bool
+	kubeletErr              error
+}
+
+// updateStatus reports the effective configuration, the collector health and
+// the conditions derived from them
+func (r *ConnectorConfigReconciler) updateStatus(ctx context.Context, p *pipeline, connConfig *hakongov1alpha1.ConnectorConfig, unknown []string, sources sourceHealth) {
+	status := &connConfig.Status
+	status.EffectiveConfig = effectiveConfigStatus(p.collectorConfig, p.remoteConfig, p.remoteConfigFetched)
+	status.Collectors = collectorStatuses(p.scheduler.Status(), p.breakers)
+	for _, c := range status.Collectors {
+		if c.LastRunTime != nil && (status.LastCollectionTime == nil || status.LastCollectionTime.Before(c.LastRunTime)) {
+			status.LastCollectionTime = c.LastRunTime
+		}
+	}
+
+	status.PrometheusQueries = nil
+	if p.prometheusClient != nil {
+		status.PrometheusQueries = prometheusQueryStatus(p.prometheusClient.Stats())
+	}
+
+	p.mu.Lock()
+	if !p.lastUpload.IsZero() {
+		status.LastUploadTime = &metav1.Time{Time: p.lastUpload}
+	}
+	p.mu.Unlock()
+
+	for _, condition := range conditions(p.apiClient.Health(), sources, status.Collectors, unknown, r.registry.Names()) {
+		condition.ObservedGeneration = connConfig.Generation
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+	if !sources.prometheusConfigured {
+		meta.RemoveStatusCondition(&status.Conditions, hakongov1alpha1.ConditionPrometheusReachable)
+	}
+	if !sources.metricsServerConfigured {
+		meta.RemoveStatusCondition(&status.Conditions, hakongov1alpha1.ConditionMetricsServerAvailable)
+	}
+	if !sources.kubeletConfigured {
+		meta.RemoveStatusCondition(&status.Conditions, hakongov1alpha1.ConditionKubeletReachable)
+	}
+
+	if err := r.Status().Update(ctx, connConfig); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update ConnectorConfig status")
+	}
+}
+
+// setNotReady reports a reconcile failure in the Ready condition
+func (r *ConnectorConfigReconciler) setNotReady(ctx context.Context, connConfig *hakongov1alpha1.ConnectorConfig, reason string, err error) {
+	meta.SetStatusCondition(&connConfig.Status.Conditions, metav1.Condition{
+		Type:               hakongov1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: connConfig.Generation,
+		Reason:             reason,
+		Message:            err.Error(),
+	})
+	if err := r.Status().Update(ctx, connConfig); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update ConnectorConfig status")
+	}
+}
+
+// conditions derives the status conditions. Conditions for metrics sources
+// that are not configured are omitted.
+func conditions(apiHealth api.Health, sources sourceHealth, collectors []hakongov1alpha1.CollectorStatus, unknown, known []string) []metav1.Condition {
+	var result []metav1.Condition
+
+	apiReachable := apiCondition(apiHealth)
+	result = append(result, apiReachable)
+
+	if sources.prometheusConfigured {
+		result = append(result, probeCondition(hakongov1alpha1.ConditionPrometheusReachable, sources.prometheusErr, "Reachable", "Unreachable"))
+	}
+	if sources.metricsServerConfigured {
+		result = append(result, probeCondition(hakongov1alpha1.ConditionMetricsServerAvailable, sources.metricsServerErr, "Available", "Unavailable"))
+	}
+	var kubeletReachable metav1.Condition
+	if sources.kubeletConfigured {
+		kubeletReachable = kubeletCondition(sources.kubeletErr, collectors)
+		result = append(result, kubeletReachable)
+	}
+
+	if len(unknown) > 0 {
+		result = append(result, metav1.Condition{
+			Type:   hakongov1alpha1.ConditionCollectorsValid,
+			Status: metav1.ConditionFalse,
+			Reason: "UnknownCollector",
+			Message: fmt.Sprintf("unknown collectors %s, known collectors are %s",
+				strings.Join(unknown, ", "), strings.Join(known, ", ")),
+		})
+	} else {
+		result = append(result, metav1.Condition{
+			Type:    hakongov1alpha1.ConditionCollectorsValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  "CollectorsKnown",
+			Message: "all collectors in the spec are known",
+		})
+	}
+
+	var failing, degraded []string
+	for _, c := range collectors {
+		switch {
+		case c.ConsecutiveFailures > 0:
+			failing = append(failing, c.Name)
+		case c.Degraded != "":
+			degraded = append(degraded, c.Name)
+		}
+	}
+
+	// Degraded lists everything that limits the collected data
+	var reasons, messages []string
+	if len(failing) > 0 {
+		reasons = append(reasons, "CollectorsFailing")
+		messages = append(messages, "failing collectors: "+strings.Join(failing, ", "))
+	}
+	if len(degraded) > 0 {
+		reasons = append(reasons, "CollectorsDegraded")
+		messages = append(messages, "collectors missing part of their data: "+strings.Join(degraded, ", "))
+	}
+	if len(unknown) > 0 {
+		reasons = append(reasons, "UnknownCollector")
+		messages = append(messages, "unknown collectors: "+strings.Join(unknown, ", "))
+	}
+	if sources.prometheusConfigured && sources.prometheusErr != nil {
+		reasons = append(reasons, "PrometheusUnreachable")
+		messages = append(messages, "Prometheus is unreachable")
+	}
+	if sources.metricsServerConfigured && sources.metricsServerErr != nil {
+		reasons = append(reasons, "MetricsServerUnavailable")
+		messages = append(messages, "Metrics Server is unavailable")
+	}
+	if kubeletReachable.Status == metav1.ConditionFalse {
+		reasons = append(reasons, "KubeletUnreachable")
+		messages = append(messages, "kubelet stats summary is unreachable")
+	}
+	if len(reasons) > 0 {
+		result = append(result, metav1.Condition{
+			Type:    hakongov1alpha1.ConditionDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasons[0],
+			Message: strings.Join(messages, "; "),
+		})
+	} else {
+		result = append(result, metav1.Condition{
+			Type:    hakongov1alpha1.ConditionDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Healthy",
+			Message: "all collectors and metrics sources are healthy",
+		})
+	}
+
+	// Ready means metrics are being collected and delivered
+	ready := metav1.Condition{
+		Type:    hakongov1alpha1.ConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Collecting",
+		Message: fmt.Sprintf("%d collector(s) scheduled", len(collectors)),
+	}
+	switch {
+	case apiReachable.Status == metav1.ConditionFalse:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "APIUnreachable"
+		ready.Message = apiReachable.Message
+	case len(collectors) == 0:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "NoCollectors"
+		ready.Message = "no collectors are scheduled"
+	case len(failing) == len(collectors):
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "CollectorsFailing"
+		ready.Message = "all collectors are failing"
+	}
+	result = append(result, ready)
+
+	return result
+}
+
+// apiCondition describes whether the HakonGo API answered the last request.
+// Requests rejected because of their content still count as reachable.
+func apiCondition(health api.Health) metav1.Condition {
+	condition := metav1.Condition{Type: hakongov1alpha1.ConditionAPIReachable}
+
+	var apiErr *api.APIError
+	switch {
+	case health.LastRequest.IsZero():
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "NoRequests"
+		condition.Message = "no request has been sent yet"
+	case health.LastError == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RequestSucceeded"
+		condition.Message = "last request succeeded"
+	case api.IsAuthError(health.LastError):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Unauthorized"
+		condition.Message = health.LastError.Error()
+	case errors.As(health.LastError, &apiErr) && !api.IsRetryable(health.LastError):
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "RequestRejected"
+		condition.Message = health.LastError.Error()
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Unreachable"
+		condition.Message = health.LastError.Error()
+	}
+	return condition
+}
+
+// probeCondition describes the outcome of probing a metrics source
+func probeCondition(conditionType string, err error, okReason, failedReason string) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    conditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  failedReason,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  okReason,
+		Message: "probe succeeded",
+	}
+}
+
+// kubeletCondition describes whether the kubelet stats summaries can be read.
+// The probe reads a single node, so the nodes the kubelet collector could not
+// read in its last run also make the condition false.
+func kubeletCondition(probeErr error, collectors []hakongov1alpha1.CollectorStatus) metav1.Condition {
+	condition := probeCondition(hakongov1alpha1.ConditionKubeletReachable, probeErr, "Reachable", "Unreachable")
+	if probeErr != nil {
+		return condition
+	}
+	for _, c := range collectors {
+		if c.Name == kubeletCollector && c.Degraded != "" {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "PartiallyUnreachable"
+			condition.Message = c.Degraded
+		}
+	}
+	return condition
+}
+
+// collectorStatuses describes the collector schedule and health for the ConnectorConfig status
+func collectorStatuses(jobs []scheduler.JobStatus, breakers map[string]*collector.Breaker) []hakongov1alpha1.CollectorStatus {
+	statuses := make([]hakongov1alpha1.CollectorStatus, 0, len(jobs))
+	for _, job := range jobs {
+		status := hakongov1alpha1.CollectorStatus{
+			Name:     job.Name,
+			Interval: job.Interval.String(),
+		}
+		if !job.LastRun.IsZero() {
+			status.LastRunTime = &metav1.Time{Time: job.LastRun}
+			status.LastRunDuration = job.LastDuration.Round(time.Millisecond).String()
+		}
+		if !job.NextRun.IsZero() {
+			status.NextRunTime = &metav1.Time{Time: job.NextRun}
+		}
+		if job.LastError != nil {
+			status.LastError = job.LastError.Error()
+		}
+		if b, ok := breakers[job.Name]; ok {
+			health := b.Health()
+			status.ConsecutiveFailures = int32(health.ConsecutiveFailures)
+			status.ItemCount = int32(health.LastItemCount)
+			if health.Degraded != nil {
+				status.Degraded = health.Degraded.Error()
+			}
+			if !health.LastSuccess.IsZero() {
+				status.LastSuccessTime = &metav1.Time{Time: health.LastSuccess}
+			}
+			if health.ConsecutiveFailures > 0 {
+				status.LastErrorClass = string(health.LastErrorClass)
+			}
+			if !health.OpenUntil.IsZero() {
+				status.CircuitOpenUntil = &metav1.Time{Time: health.OpenUntil}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// prometheusQueryStatus describes the Prometheus query statistics for the ConnectorConfig status
+func prometheusQueryStatus(stats metrics.QueryStats) *hakongov1alpha1.PrometheusQueryStatus {
+	status := &hakongov1alpha1.PrometheusQueryStatus{
+		Queries:  stats.Queries,
+		Errors:   stats.Errors,
+		Timeouts: stats.Timeouts,
+	}
+	if stats.Queries > 0 {
+		status.AverageLatency = stats.AverageDuration().Round(time.Millisecond).String()
+		status.MaxLatency = stats.MaxDuration.Round(time.Millisecond).String()
+	}
+	return status
+}
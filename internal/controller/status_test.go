@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	hakongov1alpha1 "github.com/hakongo/kubernetes-connector/api/v1alpha1"
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAPICondition(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		health api.Health
+		status metav1.ConditionStatus
+		reason string
+	}{
+		{"no requests", api.Health{}, metav1.ConditionUnknown, "NoRequests"},
+		{"success", api.Health{LastRequest: now}, metav1.ConditionTrue, "RequestSucceeded"},
+		{"unauthorized", api.Health{LastRequest: now, LastError: &api.APIError{StatusCode: http.StatusUnauthorized}}, metav1.ConditionFalse, "Unauthorized"},
+		{"rejected", api.Health{LastRequest: now, LastError: &api.APIError{StatusCode: http.StatusBadRequest}}, metav1.ConditionTrue, "RequestRejected"},
+		{"server error", api.Health{LastRequest: now, LastError: &api.APIError{StatusCode: http.StatusBadGateway}}, metav1.ConditionFalse, "Unreachable"},
+		{"transport error", api.Health{LastRequest: now, LastError: errors.New("connection refused")}, metav1.ConditionFalse, "Unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := apiCondition(tt.health)
+			assert.Equal(t, tt.status, condition.Status)
+			assert.Equal(t, tt.reason, condition.Reason)
+		})
+	}
+}
+
+func TestConditions_Healthy(t *testing.T) {
+	collectors := []hakongov1alpha1.CollectorStatus{{Name: "pod"}, {Name: "node"}}
+	sources := sourceHealth{metricsServerConfigured: true}
+
+	result := conditions(api.Health{LastRequest: time.Now()}, sources, collectors, nil, nil)
+
+	assert.True(t, meta.IsStatusConditionTrue(result, hakongov1alpha1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionFalse(result, hakongov1alpha1.ConditionDegraded))
+	assert.True(t, meta.IsStatusConditionTrue(result, hakongov1alpha1.ConditionMetricsServerAvailable))
+	assert.Nil(t, meta.FindStatusCondition(result, hakongov1alpha1.ConditionPrometheusReachable))
+}
+
+func TestConditions_KubeletUnreachable(t *testing.T) {
+	sources := sourceHealth{kubeletConfigured: true, kubeletErr: errors.New("forbidden")}
+
+	result := conditions(api.Health{LastRequest: time.Now()}, sources, []hakongov1alpha1.CollectorStatus{{Name: "kubelet"}}, nil, nil)
+
+	assert.True(t, meta.IsStatusConditionFalse(result, hakongov1alpha1.ConditionKubeletReachable))
+	degraded := meta.FindStatusCondition(result, hakongov1alpha1.ConditionDegraded)
+	assert.Equal(t, metav1.ConditionTrue, degraded.Status)
+	assert.Equal(t, "KubeletUnreachable", degraded.Reason)
+}
+
+func TestConditions_KubeletPartiallyUnreachable(t *testing.T) {
+	sources := sourceHealth{kubeletConfigured: true}
+	collectors := []hakongov1alpha1.CollectorStatus{{Name: "kubelet", Degraded: "failed to read the stats summary of 1 of 2 nodes"}}
+
+	result := conditions(api.Health{LastRequest: time.Now()}, sources, collectors, nil, nil)
+
+	kubelet := meta.FindStatusCondition(result, hakongov1alpha1.ConditionKubeletReachable)
+	assert.Equal(t, metav1.ConditionFalse, kubelet.Status)
+	assert.Equal(t, "PartiallyUnreachable", kubelet.Reason)
+	assert.Equal(t, collectors[0].Degraded, kubelet.Message)
+	degraded := meta.FindStatusCondition(result, hakongov1alpha1.ConditionDegraded)
+	assert.Equal(t, "CollectorsDegraded", degraded.Reason)
+	assert.Contains(t, degraded.Message, "kubelet stats summary is unreachable")
+	assert.True(t, meta.IsStatusConditionTrue(result, hakongov1alpha1.ConditionReady))
+}
+
+func TestConditions_Degraded(t *testing.T) {
+	collectors := []hakongov1alpha1.CollectorStatus{{Name: "pod"}, {Name: "ingress", ConsecutiveFailures: 2}}
+	sources := sourceHealth{prometheusConfigured: true, prometheusErr: errors.New("connection refused")}
+
+	result := conditions(api.Health{LastRequest: time.Now()}, sources, collectors, []string{"gpu"}, []string{"pod", "ingress"})
+
+	assert.True(t, meta.IsStatusConditionTrue(result, hakongov1alpha1.ConditionReady), "a single failing collector does not stop collection")
+	degraded := meta.FindStatusCondition(result, hakongov1alpha1.ConditionDegraded)
+	assert.Equal(t, metav1.ConditionTrue, degraded.Status)
+	assert.Equal(t, "CollectorsFailing", degraded.Reason)
+	assert.Contains(t, degraded.Message, "ingress")
+	assert.Contains(t, degraded.Message, "gpu")
+	assert.Contains(t, degraded.Message, "Prometheus")
+	assert.True(t, meta.IsStatusConditionFalse(result, hakongov1alpha1.ConditionPrometheusReachable))
+	assert.True(t, meta.IsStatusConditionFalse(result, hakongov1alpha1.ConditionCollectorsValid))
+}
+
+func TestConditions_NotReady(t *testing.T) {
+	collectors := []hakongov1alpha1.CollectorStatus{{Name: "pod", ConsecutiveFailures: 1}}
+
+	result := conditions(api.Health{LastRequest: time.Now()}, sourceHealth{}, collectors, nil, nil)
+	assert.Equal(t, "CollectorsFailing", meta.FindStatusCondition(result, hakongov1alpha1.ConditionReady).Reason)
+
+	unreachable := api.Health{LastRequest: time.Now(), LastError: errors.New("connection refused")}
+	result = conditions(unreachable, sourceHealth{}, []hakongov1alpha1.CollectorStatus{{Name: "pod"}}, nil, nil)
+	assert.Equal(t, "APIUnreachable", meta.FindStatusCondition(result, hakongov1alpha1.ConditionReady).Reason)
+}
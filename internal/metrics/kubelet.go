@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// KubeletClient reads the kubelet /stats/summary endpoint of each node
+// through the API server node proxy
+type KubeletClient struct {
+	kubeClient kubernetes.Interface
+}
+
+// NewKubeletClient creates a kubelet client. The caller needs get
+// permission on nodes/proxy.
+func NewKubeletClient(kubeClient kubernetes.Interface) *KubeletClient {
+	return &KubeletClient{kubeClient: kubeClient}
+}
+
+// Summary is the part of the kubelet stats summary the collectors read
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+// NodeStats identifies the node of the summary
+type NodeStats struct {
+	NodeName string `json:"nodeName"`
+}
+
+// PodStats holds the stats of a pod in the kubelet summary
+type PodStats struct {
+	PodRef           PodReference     `json:"podRef"`
+	Containers       []ContainerStats `json:"containers,omitempty"`
+	CPU              *CPUStats        `json:"cpu,omitempty"`
+	Memory           *MemoryStats     `json:"memory,omitempty"`
+	Network          *NetworkStats    `json:"network,omitempty"`
+	Volumes          []VolumeStats    `json:"volume,omitempty"`
+	EphemeralStorage *FsStats         `json:"ephemeral-storage,omitempty"`
+}
+
+// PodReference identifies a pod in the kubelet summary
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// ContainerStats holds the stats of a container in the kubelet summary
+type ContainerStats struct {
+	Name   string       `json:"name"`
+	CPU    *CPUStats    `json:"cpu,omitempty"`
+	Memory *MemoryStats `json:"memory,omitempty"`
+	Rootfs *FsStats     `json:"rootfs,omitempty"`
+	Logs   *FsStats     `json:"logs,omitempty"`
+}
+
+// CPUStats holds CPU usage. UsageNanoCores is averaged by the kubelet over
+// its sampling interval.
+type CPUStats struct {
+	Time                 time.Time `json:"time"`
+	UsageNanoCores       *uint64   `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64   `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+// MemoryStats holds memory usage. PageFaults and MajorPageFaults are
+// cumulative counters.
+type MemoryStats struct {
+	Time            time.Time `json:"time"`
+	AvailableBytes  *uint64   `json:"availableBytes,omitempty"`
+	UsageBytes      *uint64   `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64   `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64   `json:"rssBytes,omitempty"`
+	PageFaults      *uint64   `json:"pageFaults,omitempty"`
+	MajorPageFaults *uint64   `json:"majorPageFaults,omitempty"`
+}
+
+// NetworkStats holds the cumulative traffic counters of the default
+// interface of a pod
+type NetworkStats struct {
+	Time     time.Time `json:"time"`
+	RxBytes  *uint64   `json:"rxBytes,omitempty"`
+	RxErrors *uint64   `json:"rxErrors,omitempty"`
+	TxBytes  *uint64   `json:"txBytes,omitempty"`
+	TxErrors *uint64   `json:"txErrors,omitempty"`
+}
+
+// FsStats holds the usage of a filesystem
+type FsStats struct {
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
+	Inodes         *uint64 `json:"inodes,omitempty"`
+	InodesFree     *uint64 `json:"inodesFree,omitempty"`
+	InodesUsed     *uint64 `json:"inodesUsed,omitempty"`
+}
+
+// VolumeStats holds the filesystem stats of a pod volume. PVCRef is set for
+// volumes backed by a persistent volume claim.
+type VolumeStats struct {
+	FsStats
+	Name   string        `json:"name"`
+	PVCRef *PodReference `json:"pvcRef,omitempty"`
+}
+
+// Value returns the value of an optional summary field, zero when unset
+func Value(v *uint64) float64 {
+	if v == nil {
+		return 0
+	}
+	return float64(*v)
+}
+
+// GetSummary returns the stats summary of the named node
+func (c *KubeletClient) GetSummary(ctx context.Context, node string) (*Summary, error) {
+	raw, err := c.kubeClient.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(node).
+		SubResource("proxy").
+		Suffix("stats", "summary").
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats summary of node %s: %w", node, err)
+	}
+
+	var summary Summary
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode stats summary of node %s: %w", node, err)
+	}
+	return &summary, nil
+}
+
+// ClaimKey identifies a persistent volume claim
+type ClaimKey struct {
+	Namespace string
+	Name      string
+}
+
+// ClaimStats holds the filesystem usage of the volume of a persistent volume
+// claim
+type ClaimStats struct {
+	UsedBytes      float64
+	AvailableBytes float64
+	CapacityBytes  float64
+	Inodes         float64
+	InodesUsed     float64
+	InodesFree     float64
+}
+
+// ClaimStats returns the volume stats of the claims mounted by the pods of
+// the summary
+func (s *Summary) ClaimStats() map[ClaimKey]*ClaimStats {
+	claims := make(map[ClaimKey]*ClaimStats)
+	for _, pod := range s.Pods {
+		for _, volume := range pod.Volumes {
+			if volume.PVCRef == nil {
+				continue
+			}
+			// A claim mounted by several pods reports the same filesystem
+			claims[ClaimKey{Namespace: volume.PVCRef.Namespace, Name: volume.PVCRef.Name}] = &ClaimStats{
+				UsedBytes:      Value(volume.UsedBytes),
+				AvailableBytes: Value(volume.AvailableBytes),
+				CapacityBytes:  Value(volume.CapacityBytes),
+				Inodes:         Value(volume.Inodes),
+				InodesUsed:     Value(volume.InodesUsed),
+				InodesFree:     Value(volume.InodesFree),
+			}
+		}
+	}
+	return claims
+}
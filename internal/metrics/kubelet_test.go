@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const testSummary = `{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "db-0", "namespace": "default"},
+      "volume": [
+        {"name": "data", "pvcRef": {"name": "data-db-0", "namespace": "default"},
+         "usedBytes": 1073741824, "availableBytes": 9663676416, "capacityBytes": 10737418240,
+         "inodes": 655360, "inodesUsed": 1200, "inodesFree": 654160},
+        {"name": "kube-api-access", "usedBytes": 4096}
+      ]
+    }
+  ]
+}`
+
+// newTestKubeletClient serves the stats summary of node-1 through a fake
+// API server node proxy
+func newTestKubeletClient(t *testing.T) *KubeletClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/node-1/proxy/stats/summary" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testSummary)
+	}))
+	t.Cleanup(server.Close)
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	return NewKubeletClient(kubeClient)
+}
+
+func TestKubeletClient_GetSummary(t *testing.T) {
+	client := newTestKubeletClient(t)
+
+	summary, err := client.GetSummary(context.Background(), "node-1")
+	assert.NoError(t, err)
+
+	claims := summary.ClaimStats()
+	assert.Len(t, claims, 1, "volumes without a claim are ignored")
+	stats := claims[ClaimKey{Namespace: "default", Name: "data-db-0"}]
+	assert.Equal(t, float64(1<<30), stats.UsedBytes)
+	assert.Equal(t, float64(10<<30), stats.CapacityBytes)
+	assert.Equal(t, 1200.0, stats.InodesUsed)
+	assert.Equal(t, 654160.0, stats.InodesFree)
+
+	_, err = client.GetSummary(context.Background(), "node-2")
+	assert.Error(t, err)
+}
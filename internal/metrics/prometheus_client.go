@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
@@ -10,130 +12,440 @@ import (
 	"github.com/prometheus/common/model"
 )
 
+const (
+	// DefaultQueryTimeout is used when ClientConfig.QueryTimeout is not set
+	DefaultQueryTimeout = 30 * time.Second
+
+	// DefaultMaxConcurrentQueries is used when ClientConfig.MaxConcurrentQueries is not set
+	DefaultMaxConcurrentQueries = 10
+)
+
+// ClientConfig contains configuration for the Prometheus client
+type ClientConfig struct {
+	// URL is the base URL of the Prometheus API
+	URL string
+
+	// Auth contains the credentials and TLS settings
+	Auth AuthConfig
+
+	// QueryTimeout bounds every query
+	QueryTimeout time.Duration
+
+	// MaxConcurrentQueries limits the queries in flight at once. Further
+	// queries wait for a free slot.
+	MaxConcurrentQueries int
+
+	// Queries customizes the queries run by the collectors
+	Queries QueryConfig
+}
+
 // PrometheusClient wraps the Prometheus API client
 type PrometheusClient struct {
-	api     v1.API
-	baseURL string
+	api          v1.API
+	baseURL      string
+	queryTimeout time.Duration
+	queries      *querySet
+
+	// slots holds one token per query in flight
+	slots chan struct{}
+
+	statsMu sync.Mutex
+	stats   QueryStats
 }
 
 // NewPrometheusClient creates a new Prometheus client
 func NewPrometheusClient(baseURL string) (*PrometheusClient, error) {
+	return NewPrometheusClientWithConfig(ClientConfig{URL: baseURL})
+}
+
+// NewPrometheusClientWithConfig creates a Prometheus client with the given
+// credentials, TLS settings and query limits
+func NewPrometheusClientWithConfig(config ClientConfig) (*PrometheusClient, error) {
+	if config.QueryTimeout <= 0 {
+		config.QueryTimeout = DefaultQueryTimeout
+	}
+	if config.MaxConcurrentQueries <= 0 {
+		config.MaxConcurrentQueries = DefaultMaxConcurrentQueries
+	}
+
+	queries, err := newQuerySet(config.Queries)
+	if err != nil {
+		return nil, err
+	}
+
+	roundTripper, err := NewRoundTripper(config.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("error creating transport: %w", err)
+	}
+
 	client, err := api.NewClient(api.Config{
-		Address: baseURL,
+		Address:      config.URL,
+		RoundTripper: roundTripper,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating client: %w", err)
 	}
 
 	return &PrometheusClient{
-		api:     v1.NewAPI(client),
-		baseURL: baseURL,
+		api:          v1.NewAPI(client),
+		baseURL:      config.URL,
+		queryTimeout: config.QueryTimeout,
+		queries:      queries,
+		slots:        make(chan struct{}, config.MaxConcurrentQueries),
 	}, nil
 }
 
 // QueryRange performs a range query against Prometheus
 func (c *PrometheusClient) QueryRange(ctx context.Context, query string, r v1.Range) (model.Value, error) {
-	result, _, err := c.api.QueryRange(ctx, query, r)
-	if err != nil {
-		return nil, fmt.Errorf("error querying Prometheus: %w", err)
-	}
-	return result, nil
+	return c.do(ctx, func(ctx context.Context) (model.Value, error) {
+		result, _, err := c.api.QueryRange(ctx, query, r, v1.WithTimeout(c.queryTimeout))
+		return result, err
+	})
 }
 
 // Query performs an instant query against Prometheus
 func (c *PrometheusClient) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
-	result, _, err := c.api.Query(ctx, query, ts)
+	return c.do(ctx, func(ctx context.Context) (model.Value, error) {
+		result, _, err := c.api.Query(ctx, query, ts, v1.WithTimeout(c.queryTimeout))
+		return result, err
+	})
+}
+
+// queryNamed performs the named query for the collection interval. With a
+// window it runs a range query ending at ts, otherwise an instant query at ts.
+func (c *PrometheusClient) queryNamed(ctx context.Context, name string, ts time.Time, window, interval time.Duration) ([]series, error) {
+	query, err := c.queries.render(name, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var result model.Value
+	if window > 0 {
+		result, err = c.QueryRange(ctx, query, v1.Range{
+			Start: ts.Add(-window),
+			End:   ts,
+			Step:  rangeStep(window),
+		})
+	} else {
+		result, err = c.Query(ctx, query, ts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seriesOf(result), nil
+}
+
+// do runs a query once a slot is free and bounds it by the query timeout. A
+// query that runs out of time returns an error wrapping context.DeadlineExceeded.
+func (c *PrometheusClient) do(ctx context.Context, query func(ctx context.Context) (model.Value, error)) (model.Value, error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error waiting for a Prometheus query slot: %w", ctx.Err())
+	}
+	defer func() { <-c.slots }()
+
+	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	defer cancel()
+
+	c.statsMu.Lock()
+	c.stats.InFlight++
+	c.statsMu.Unlock()
+
+	start := time.Now()
+	result, err := query(queryCtx)
+	timedOut := err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded)
+	c.record(time.Since(start), err, timedOut)
+
+	if timedOut {
+		return nil, fmt.Errorf("error querying Prometheus: timed out after %s: %w", c.queryTimeout, context.DeadlineExceeded)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error querying Prometheus: %w", err)
 	}
 	return result, nil
 }
 
-// GetContainerMetrics returns container resource usage metrics
-func (c *PrometheusClient) GetContainerMetrics(ctx context.Context, namespace, pod string) (*ContainerMetrics, error) {
+// PodKey identifies a pod in the results of cluster-wide queries
+type PodKey struct {
+	Namespace string
+	Name      string
+}
+
+// GetClusterContainerMetrics returns the resource usage of every container
+// in the cluster indexed by pod, including CPU throttling, RSS, page faults
+// and network traffic when the query set has them. It runs one query per metric, so the
+// number of queries does not grow with the cluster. With a window the usage is the
+// latest sample of a range query over the window, and the statistics of the
+// window are returned as well.
+func (c *PrometheusClient) GetClusterContainerMetrics(ctx context.Context, window time.Duration) (map[PodKey]*ContainerMetrics, error) {
 	timeNow := time.Now()
 
 	// CPU usage in cores
-	cpuQuery := fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{namespace="%s",pod="%s"}[5m])) by (container)`, namespace, pod)
-	cpuResult, err := c.Query(ctx, cpuQuery, timeNow)
+	cpuResult, err := c.queryNamed(ctx, QueryContainerCPU, timeNow, window, window)
 	if err != nil {
 		return nil, fmt.Errorf("error querying CPU metrics: %w", err)
 	}
 
 	// Memory usage in bytes
-	memQuery := fmt.Sprintf(`sum(container_memory_working_set_bytes{namespace="%s",pod="%s"}) by (container)`, namespace, pod)
-	memResult, err := c.Query(ctx, memQuery, timeNow)
+	memResult, err := c.queryNamed(ctx, QueryContainerMemory, timeNow, window, window)
 	if err != nil {
 		return nil, fmt.Errorf("error querying memory metrics: %w", err)
 	}
 
-	metrics := &ContainerMetrics{
-		CPU:    make(map[string]float64),
-		Memory: make(map[string]float64),
+	// Network rates are averaged over the window like CPU
+	var networkResult []series
+	if c.queries.has(QueryPodNetwork) {
+		networkResult, err = c.queryNamed(ctx, QueryPodNetwork, timeNow, window, window)
+		if err != nil {
+			return nil, fmt.Errorf("error querying network metrics: %w", err)
+		}
+	}
+
+	// Throttling, RSS and page faults are instant queries whatever the window.
+	// The counters report their increase over the collection interval, so
+	// the values of consecutive collections add up.
+	var throttlingResult, rssResult, faultsResult []series
+	for _, q := range []struct {
+		name   string
+		result *[]series
+		what   string
+	}{
+		{QueryContainerCPUThrottling, &throttlingResult, "CPU throttling"},
+		{QueryContainerMemoryRSS, &rssResult, "memory RSS"},
+		{QueryContainerPageFaults, &faultsResult, "page fault"},
+	} {
+		if !c.queries.has(q.name) {
+			continue
+		}
+		*q.result, err = c.queryNamed(ctx, q.name, timeNow, 0, window)
+		if err != nil {
+			return nil, fmt.Errorf("error querying %s metrics: %w", q.what, err)
+		}
+	}
+
+	pods := make(map[PodKey]*ContainerMetrics)
+	pod := func(metric model.Metric) *ContainerMetrics {
+		key := PodKey{Namespace: c.queries.label(metric, LabelNamespace), Name: c.queries.label(metric, LabelPod)}
+		m, ok := pods[key]
+		if !ok {
+			m = &ContainerMetrics{
+				CPU:             make(map[string]float64),
+				Memory:          make(map[string]float64),
+				CPUStats:        make(map[string]UsageStats),
+				MemoryStats:     make(map[string]UsageStats),
+				Throttling:      make(map[string]float64),
+				RSS:             make(map[string]float64),
+				PageFaults:      make(map[string]float64),
+				MajorPageFaults: make(map[string]float64),
+			}
+			pods[key] = m
+		}
+		return m
 	}
 
 	// Parse CPU results
-	if vector, ok := cpuResult.(model.Vector); ok {
-		for _, sample := range vector {
-			container := string(sample.Metric["container"])
-			metrics.CPU[container] = float64(sample.Value)
+	for _, s := range cpuResult {
+		m, container := pod(s.metric), c.queries.label(s.metric, LabelContainer)
+		m.CPU[container] = s.value
+		if window > 0 {
+			m.CPUStats[container] = s.stats
 		}
 	}
 
 	// Parse memory results
-	if vector, ok := memResult.(model.Vector); ok {
-		for _, sample := range vector {
-			container := string(sample.Metric["container"])
-			metrics.Memory[container] = float64(sample.Value)
+	for _, s := range memResult {
+		m, container := pod(s.metric), c.queries.label(s.metric, LabelContainer)
+		m.Memory[container] = s.value
+		if window > 0 {
+			m.MemoryStats[container] = s.stats
+		}
+	}
+
+	for _, s := range networkResult {
+		pod(s.metric).Network.set(c.queries.label(s.metric, LabelTraffic), s.rate(window))
+	}
+	for _, s := range throttlingResult {
+		pod(s.metric).Throttling[c.queries.label(s.metric, LabelContainer)] = s.value
+	}
+	for _, s := range rssResult {
+		pod(s.metric).RSS[c.queries.label(s.metric, LabelContainer)] = s.value
+	}
+	for _, s := range faultsResult {
+		m, container := pod(s.metric), c.queries.label(s.metric, LabelContainer)
+		switch c.queries.label(s.metric, LabelFailureType) {
+		case FailureTypePageFault:
+			m.PageFaults[container] = s.value
+		case FailureTypeMajorPageFault:
+			m.MajorPageFaults[container] = s.value
 		}
 	}
 
-	return metrics, nil
+	return pods, nil
 }
 
-// GetNodeMetrics returns node resource usage metrics
-func (c *PrometheusClient) GetNodeMetrics(ctx context.Context, nodeName string) (*NodeMetrics, error) {
+// GetClusterNodeMetrics returns the resource usage of every node indexed by
+// node name. It runs one query per metric, so the number of queries does not
+// grow with the cluster. With a window the usage is the latest sample of a
+// range query over the window, and the statistics of the window are returned
+// as well.
+func (c *PrometheusClient) GetClusterNodeMetrics(ctx context.Context, window time.Duration) (map[string]*NodeMetrics, error) {
 	timeNow := time.Now()
 
 	// CPU usage in cores
-	cpuQuery := fmt.Sprintf(`sum(rate(node_cpu_seconds_total{mode!="idle",node="%s"}[5m]))`, nodeName)
-	cpuResult, err := c.Query(ctx, cpuQuery, timeNow)
+	cpuResult, err := c.queryNamed(ctx, QueryNodeCPU, timeNow, window, window)
 	if err != nil {
 		return nil, fmt.Errorf("error querying CPU metrics: %w", err)
 	}
 
 	// Memory usage in bytes
-	memQuery := fmt.Sprintf(`node_memory_MemTotal_bytes{node="%s"} - node_memory_MemAvailable_bytes{node="%s"}`, nodeName, nodeName)
-	memResult, err := c.Query(ctx, memQuery, timeNow)
+	memResult, err := c.queryNamed(ctx, QueryNodeMemory, timeNow, window, window)
 	if err != nil {
 		return nil, fmt.Errorf("error querying memory metrics: %w", err)
 	}
 
-	metrics := &NodeMetrics{}
+	var networkResult []series
+	if c.queries.has(QueryNodeNetwork) {
+		networkResult, err = c.queryNamed(ctx, QueryNodeNetwork, timeNow, window, window)
+		if err != nil {
+			return nil, fmt.Errorf("error querying network metrics: %w", err)
+		}
+	}
+
+	nodes := make(map[string]*NodeMetrics)
+	node := func(metric model.Metric) *NodeMetrics {
+		name := c.queries.label(metric, LabelNode)
+		m, ok := nodes[name]
+		if !ok {
+			m = &NodeMetrics{}
+			nodes[name] = m
+		}
+		return m
+	}
 
 	// Parse CPU results
-	if vector, ok := cpuResult.(model.Vector); ok && len(vector) > 0 {
-		metrics.CPUUsage = float64(vector[0].Value)
+	for _, s := range cpuResult {
+		m := node(s.metric)
+		m.CPUUsage = s.value
+		m.CPUStats = s.stats
 	}
 
 	// Parse memory results
-	if vector, ok := memResult.(model.Vector); ok && len(vector) > 0 {
-		metrics.MemoryUsage = float64(vector[0].Value)
+	for _, s := range memResult {
+		m := node(s.metric)
+		m.MemoryUsage = s.value
+		m.MemoryStats = s.stats
 	}
 
-	return metrics, nil
+	for _, s := range networkResult {
+		node(s.metric).Network.set(c.queries.label(s.metric, LabelTraffic), s.rate(window))
+	}
+
+	return nodes, nil
+}
+
+// GetClusterVolumeStats returns the filesystem usage of every persistent
+// volume claim from the kubelet volume stats, in one query. It returns nil
+// when the query set has no volume stats query.
+func (c *PrometheusClient) GetClusterVolumeStats(ctx context.Context) (map[ClaimKey]*ClaimStats, error) {
+	if !c.queries.has(QueryVolumeStats) {
+		return nil, nil
+	}
+	result, err := c.queryNamed(ctx, QueryVolumeStats, time.Now(), 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error querying volume stats: %w", err)
+	}
+
+	claims := make(map[ClaimKey]*ClaimStats)
+	for _, s := range result {
+		key := ClaimKey{
+			Namespace: c.queries.label(s.metric, LabelNamespace),
+			Name:      c.queries.label(s.metric, LabelPersistentVolumeClaim),
+		}
+		stats, ok := claims[key]
+		if !ok {
+			stats = &ClaimStats{}
+			claims[key] = stats
+		}
+		switch c.queries.label(s.metric, LabelVolumeStat) {
+		case VolumeStatUsedBytes:
+			stats.UsedBytes = s.value
+		case VolumeStatAvailableBytes:
+			stats.AvailableBytes = s.value
+		case VolumeStatCapacityBytes:
+			stats.CapacityBytes = s.value
+		case VolumeStatInodes:
+			stats.Inodes = s.value
+		case VolumeStatInodesUsed:
+			stats.InodesUsed = s.value
+		case VolumeStatInodesFree:
+			stats.InodesFree = s.value
+		}
+	}
+	return claims, nil
 }
 
 // ContainerMetrics represents resource usage metrics for containers
 type ContainerMetrics struct {
 	CPU    map[string]float64 // CPU usage in cores by container
 	Memory map[string]float64 // Memory usage in bytes by container
+
+	// Statistics over the query window by container, empty for instant queries
+	CPUStats    map[string]UsageStats
+	MemoryStats map[string]UsageStats
+
+	Throttling      map[string]float64 // Seconds throttled over the query window by container
+	RSS             map[string]float64 // Resident set size in bytes by container
+	PageFaults      map[string]float64 // Page faults over the query window by container
+	MajorPageFaults map[string]float64 // Major page faults over the query window by container
+
+	Network NetworkRates // Network traffic of the pod
 }
 
 // NodeMetrics represents resource usage metrics for nodes
 type NodeMetrics struct {
 	CPUUsage    float64 // CPU usage in cores
 	MemoryUsage float64 // Memory usage in bytes
+
+	// Statistics over the query window, zero for instant queries
+	CPUStats    UsageStats
+	MemoryStats UsageStats
+
+	Network NetworkRates // Network traffic of the node
+}
+
+// NetworkRates are per-second network rates, averaged over the query window
+type NetworkRates struct {
+	RxBytes   float64
+	TxBytes   float64
+	RxPackets float64
+	TxPackets float64
+	RxErrors  float64
+	TxErrors  float64
+	RxDropped float64
+	TxDropped float64
+}
+
+// set sets the rate of the given LabelTraffic value
+func (r *NetworkRates) set(traffic string, rate float64) {
+	switch traffic {
+	case TrafficRxBytes:
+		r.RxBytes = rate
+	case TrafficTxBytes:
+		r.TxBytes = rate
+	case TrafficRxPackets:
+		r.RxPackets = rate
+	case TrafficTxPackets:
+		r.TxPackets = rate
+	case TrafficRxErrors:
+		r.RxErrors = rate
+	case TrafficTxErrors:
+		r.TxErrors = rate
+	case TrafficRxDropped:
+		r.RxDropped = rate
+	case TrafficTxDropped:
+		r.TxDropped = rate
+	}
 }
 
 // GetBaseURL returns the base URL of the Prometheus server
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const emptyVector = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+func TestPrometheusClient_QueryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewPrometheusClientWithConfig(ClientConfig{URL: server.URL, QueryTimeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Query(context.Background(), "up", time.Now())
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	stats := client.Stats()
+	assert.Equal(t, int64(1), stats.Queries)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, int64(1), stats.Timeouts)
+	assert.Equal(t, 0, stats.InFlight)
+}
+
+func TestPrometheusClient_ConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, emptyVector)
+	}))
+	defer server.Close()
+
+	client, err := NewPrometheusClientWithConfig(ClientConfig{URL: server.URL, MaxConcurrentQueries: 2})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Query(context.Background(), "up", time.Now())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+	stats := client.Stats()
+	assert.Equal(t, int64(8), stats.Queries)
+	assert.Zero(t, stats.Errors)
+	assert.Positive(t, stats.AverageDuration())
+	assert.GreaterOrEqual(t, stats.MaxDuration, stats.AverageDuration())
+}
+
+func TestPrometheusClient_WaitForSlotHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, emptyVector)
+	}))
+	defer server.Close()
+
+	client, err := NewPrometheusClientWithConfig(ClientConfig{URL: server.URL, MaxConcurrentQueries: 1})
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Query(context.Background(), "up", time.Now())
+	}()
+	assert.Eventually(t, func() bool { return client.Stats().InFlight == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.Query(ctx, "up", time.Now())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	close(release)
+	<-done
+}
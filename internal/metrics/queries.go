@@ -0,0 +1,431 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Names of the queries run by the collectors. Query templates are keyed by
+// these names.
+const (
+	QueryContainerCPU           = "containerCPU"
+	QueryContainerMemory        = "containerMemory"
+	QueryContainerCPUThrottling = "containerCPUThrottling"
+	QueryContainerMemoryRSS     = "containerMemoryRSS"
+	QueryContainerPageFaults    = "containerPageFaults"
+	QueryPodNetwork             = "podNetwork"
+	QueryNodeCPU                = "nodeCPU"
+	QueryNodeMemory             = "nodeMemory"
+	QueryNodeNetwork            = "nodeNetwork"
+	QueryVolumeStats            = "volumeStats"
+)
+
+// queryNames lists every query. Presets may leave out the container
+// throttling, RSS, page fault, network and volume queries when their source
+// has no such series; the collectors then report zero or fall back to the
+// kubelet.
+var queryNames = []string{
+	QueryContainerCPU,
+	QueryContainerMemory,
+	QueryContainerCPUThrottling,
+	QueryContainerMemoryRSS,
+	QueryContainerPageFaults,
+	QueryPodNetwork,
+	QueryNodeCPU,
+	QueryNodeMemory,
+	QueryNodeNetwork,
+	QueryVolumeStats,
+}
+
+// Labels the collectors read from query results. Results using other label
+// names are renamed with QueryConfig.LabelRenames.
+const (
+	LabelNamespace = "namespace"
+	LabelPod       = "pod"
+	LabelContainer = "container"
+	LabelNode      = "node"
+
+	// LabelFailureType tells minor (pgfault) from major (pgmajfault) page
+	// faults in the results of the page fault query
+	LabelFailureType = "failure_type"
+)
+
+// Values of LabelFailureType
+const (
+	FailureTypePageFault      = "pgfault"
+	FailureTypeMajorPageFault = "pgmajfault"
+)
+
+// LabelTraffic names the counter of each series of the network queries,
+// which return the per-second rate of every counter in one result
+const LabelTraffic = "traffic"
+
+// Values of LabelTraffic
+const (
+	TrafficRxBytes   = "rx_bytes"
+	TrafficTxBytes   = "tx_bytes"
+	TrafficRxPackets = "rx_packets"
+	TrafficTxPackets = "tx_packets"
+	TrafficRxErrors  = "rx_errors"
+	TrafficTxErrors  = "tx_errors"
+	TrafficRxDropped = "rx_dropped"
+	TrafficTxDropped = "tx_dropped"
+)
+
+// LabelPersistentVolumeClaim names the claim of the series of the volume
+// stats query
+const LabelPersistentVolumeClaim = "persistentvolumeclaim"
+
+// LabelVolumeStat names the statistic of each series of the volume stats
+// query, which returns every statistic in one result
+const LabelVolumeStat = "stat"
+
+// Values of LabelVolumeStat
+const (
+	VolumeStatUsedBytes      = "used_bytes"
+	VolumeStatAvailableBytes = "available_bytes"
+	VolumeStatCapacityBytes  = "capacity_bytes"
+	VolumeStatInodes         = "inodes"
+	VolumeStatInodesUsed     = "inodes_used"
+	VolumeStatInodesFree     = "inodes_free"
+)
+
+// trafficCounter is a network counter and the traffic it is reported as
+type trafficCounter struct {
+	traffic string
+	metric  string
+}
+
+var (
+	cadvisorNetworkCounters = []trafficCounter{
+		{TrafficRxBytes, "container_network_receive_bytes_total"},
+		{TrafficTxBytes, "container_network_transmit_bytes_total"},
+		{TrafficRxPackets, "container_network_receive_packets_total"},
+		{TrafficTxPackets, "container_network_transmit_packets_total"},
+		{TrafficRxErrors, "container_network_receive_errors_total"},
+		{TrafficTxErrors, "container_network_transmit_errors_total"},
+		{TrafficRxDropped, "container_network_receive_packets_dropped_total"},
+		{TrafficTxDropped, "container_network_transmit_packets_dropped_total"},
+	}
+
+	nodeExporterNetworkCounters = []trafficCounter{
+		{TrafficRxBytes, "node_network_receive_bytes_total"},
+		{TrafficTxBytes, "node_network_transmit_bytes_total"},
+		{TrafficRxPackets, "node_network_receive_packets_total"},
+		{TrafficTxPackets, "node_network_transmit_packets_total"},
+		{TrafficRxErrors, "node_network_receive_errs_total"},
+		{TrafficTxErrors, "node_network_transmit_errs_total"},
+		{TrafficRxDropped, "node_network_receive_drop_total"},
+		{TrafficTxDropped, "node_network_transmit_drop_total"},
+	}
+
+	// nodeExporterDevices leaves out loopback and the virtual interfaces of
+	// pods, whose traffic also crosses the physical interfaces
+	nodeExporterDevices = `device!~"lo|veth.*|cali.*|cni.*|flannel.*|cilium.*|docker.*|virbr.*"`
+)
+
+// volumeStatsQuery returns a query template for the kubelet volume stats of
+// every claim, labelled with LabelVolumeStat. A claim mounted on several
+// nodes reports the same filesystem, hence max.
+func volumeStatsQuery(matchers string) string {
+	stats := []struct{ stat, metric string }{
+		{VolumeStatUsedBytes, "kubelet_volume_stats_used_bytes"},
+		{VolumeStatAvailableBytes, "kubelet_volume_stats_available_bytes"},
+		{VolumeStatCapacityBytes, "kubelet_volume_stats_capacity_bytes"},
+		{VolumeStatInodes, "kubelet_volume_stats_inodes"},
+		{VolumeStatInodesUsed, "kubelet_volume_stats_inodes_used"},
+		{VolumeStatInodesFree, "kubelet_volume_stats_inodes_free"},
+	}
+	parts := make([]string, len(stats))
+	for i, s := range stats {
+		parts[i] = fmt.Sprintf(`label_replace(%s{%s{{.Selector}}}, "%s", "%s", "", "")`,
+			s.metric, matchers, LabelVolumeStat, s.stat)
+	}
+	return fmt.Sprintf("max(%s) by (namespace, persistentvolumeclaim, %s)", strings.Join(parts, " or "), LabelVolumeStat)
+}
+
+// networkQuery returns a query template for the rates of counters, summed by
+// the given labels and labelled with LabelTraffic
+func networkQuery(counters []trafficCounter, matchers, by string) string {
+	parts := make([]string, len(counters))
+	for i, c := range counters {
+		parts[i] = fmt.Sprintf(`label_replace(rate(%s{%s{{.Selector}}}[{{.Window}}]), "%s", "%s", "", "")`,
+			c.metric, matchers, LabelTraffic, c.traffic)
+	}
+	return fmt.Sprintf("sum(%s) by (%s, %s)", strings.Join(parts, " or "), by, LabelTraffic)
+}
+
+const (
+	// PresetDefault matches cAdvisor and node-exporter series labelled with
+	// namespace, pod, container and node
+	PresetDefault = "default"
+
+	// PresetKubePrometheusStack matches kube-prometheus-stack, where
+	// node-exporter identifies nodes by the instance label
+	PresetKubePrometheusStack = "kube-prometheus-stack"
+
+	// PresetGKEManaged matches the GKE system metrics served by Google
+	// Cloud Managed Service for Prometheus. They include no throttling, RSS,
+	// page fault or claim volume series, and network traffic in bytes only.
+	PresetGKEManaged = "gke-managed"
+
+	// PresetVictoriaMetrics matches victoria-metrics-k8s-stack
+	PresetVictoriaMetrics = "victoriametrics"
+
+	// DefaultQueryWindow is the range of rate() in the shipped templates
+	DefaultQueryWindow = "5m"
+)
+
+// Preset is a set of query templates and label renames for one Prometheus
+// distribution
+type Preset struct {
+	Templates    map[string]string
+	LabelRenames map[string]string
+}
+
+// Presets are the query sets shipped with the connector. Templates can use
+// {{.Window}}, the range of rate(), {{.Interval}}, the collection interval
+// counters report their increase over, and {{.Selector}}, the extra label
+// matchers of the config prefixed with a comma.
+var Presets = map[string]Preset{
+	PresetDefault: {
+		Templates: map[string]string{
+			QueryContainerCPU:           `sum(rate(container_cpu_usage_seconds_total{container!="",container!="POD"{{.Selector}}}[{{.Window}}])) by (namespace, pod, container)`,
+			QueryContainerMemory:        `sum(container_memory_working_set_bytes{container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerCPUThrottling: `sum(increase(container_cpu_cfs_throttled_seconds_total{container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container)`,
+			QueryContainerMemoryRSS:     `sum(container_memory_rss{container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerPageFaults:    `sum(increase(container_memory_failures_total{failure_type=~"pgfault|pgmajfault",scope="container",container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container, failure_type)`,
+			QueryPodNetwork:             networkQuery(cadvisorNetworkCounters, `pod!=""`, "namespace, pod"),
+			QueryNodeCPU:                `sum(rate(node_cpu_seconds_total{mode!="idle"{{.Selector}}}[{{.Window}}])) by (node)`,
+			QueryNodeMemory:             `sum(node_memory_MemTotal_bytes{job!=""{{.Selector}}}) by (node) - sum(node_memory_MemAvailable_bytes{job!=""{{.Selector}}}) by (node)`,
+			QueryNodeNetwork:            networkQuery(nodeExporterNetworkCounters, nodeExporterDevices, "node"),
+			QueryVolumeStats:            volumeStatsQuery(`persistentvolumeclaim!=""`),
+		},
+	},
+	PresetKubePrometheusStack: {
+		Templates: map[string]string{
+			QueryContainerCPU:           `sum(rate(container_cpu_usage_seconds_total{job="kubelet",metrics_path="/metrics/cadvisor",container!="",container!="POD"{{.Selector}}}[{{.Window}}])) by (namespace, pod, container)`,
+			QueryContainerMemory:        `sum(container_memory_working_set_bytes{job="kubelet",metrics_path="/metrics/cadvisor",container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerCPUThrottling: `sum(increase(container_cpu_cfs_throttled_seconds_total{job="kubelet",metrics_path="/metrics/cadvisor",container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container)`,
+			QueryContainerMemoryRSS:     `sum(container_memory_rss{job="kubelet",metrics_path="/metrics/cadvisor",container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerPageFaults:    `sum(increase(container_memory_failures_total{job="kubelet",metrics_path="/metrics/cadvisor",failure_type=~"pgfault|pgmajfault",scope="container",container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container, failure_type)`,
+			QueryPodNetwork:             networkQuery(cadvisorNetworkCounters, `job="kubelet",metrics_path="/metrics/cadvisor",pod!=""`, "namespace, pod"),
+			QueryNodeCPU:                `sum(rate(node_cpu_seconds_total{job="node-exporter",mode!="idle"{{.Selector}}}[{{.Window}}])) by (instance)`,
+			QueryNodeMemory:             `sum(node_memory_MemTotal_bytes{job="node-exporter"{{.Selector}}}) by (instance) - sum(node_memory_MemAvailable_bytes{job="node-exporter"{{.Selector}}}) by (instance)`,
+			QueryNodeNetwork:            networkQuery(nodeExporterNetworkCounters, `job="node-exporter",`+nodeExporterDevices, "instance"),
+			QueryVolumeStats:            volumeStatsQuery(`job="kubelet",metrics_path="/metrics",persistentvolumeclaim!=""`),
+		},
+		LabelRenames: map[string]string{"instance": LabelNode},
+	},
+	PresetGKEManaged: {
+		Templates: map[string]string{
+			QueryContainerCPU:    `sum(rate(kubernetes_io:container_cpu_core_usage_time{monitored_resource="k8s_container"{{.Selector}}}[{{.Window}}])) by (namespace_name, pod_name, container_name)`,
+			QueryContainerMemory: `sum(kubernetes_io:container_memory_used_bytes{monitored_resource="k8s_container",memory_type="non-evictable"{{.Selector}}}) by (namespace_name, pod_name, container_name)`,
+			QueryPodNetwork: networkQuery([]trafficCounter{
+				{TrafficRxBytes, "kubernetes_io:pod_network_received_bytes_count"},
+				{TrafficTxBytes, "kubernetes_io:pod_network_sent_bytes_count"},
+			}, `monitored_resource="k8s_pod"`, "namespace_name, pod_name"),
+			QueryNodeCPU:    `sum(rate(kubernetes_io:node_cpu_core_usage_time{monitored_resource="k8s_node"{{.Selector}}}[{{.Window}}])) by (node_name)`,
+			QueryNodeMemory: `sum(kubernetes_io:node_memory_used_bytes{monitored_resource="k8s_node",memory_type="non-evictable"{{.Selector}}}) by (node_name)`,
+			QueryNodeNetwork: networkQuery([]trafficCounter{
+				{TrafficRxBytes, "kubernetes_io:node_network_received_bytes_count"},
+				{TrafficTxBytes, "kubernetes_io:node_network_sent_bytes_count"},
+			}, `monitored_resource="k8s_node"`, "node_name"),
+		},
+		LabelRenames: map[string]string{
+			"namespace_name": LabelNamespace,
+			"pod_name":       LabelPod,
+			"container_name": LabelContainer,
+			"node_name":      LabelNode,
+		},
+	},
+	PresetVictoriaMetrics: {
+		Templates: map[string]string{
+			QueryContainerCPU:           `sum(rate(container_cpu_usage_seconds_total{job="kubelet",container!="",container!="POD"{{.Selector}}}[{{.Window}}])) by (namespace, pod, container)`,
+			QueryContainerMemory:        `sum(container_memory_working_set_bytes{job="kubelet",container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerCPUThrottling: `sum(increase(container_cpu_cfs_throttled_seconds_total{job="kubelet",container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container)`,
+			QueryContainerMemoryRSS:     `sum(container_memory_rss{job="kubelet",container!="",container!="POD"{{.Selector}}}) by (namespace, pod, container)`,
+			QueryContainerPageFaults:    `sum(increase(container_memory_failures_total{job="kubelet",failure_type=~"pgfault|pgmajfault",scope="container",container!="",container!="POD"{{.Selector}}}[{{.Interval}}])) by (namespace, pod, container, failure_type)`,
+			QueryPodNetwork:             networkQuery(cadvisorNetworkCounters, `job="kubelet",pod!=""`, "namespace, pod"),
+			QueryNodeCPU:                `sum(rate(node_cpu_seconds_total{job="node-exporter",mode!="idle"{{.Selector}}}[{{.Window}}])) by (instance)`,
+			QueryNodeMemory:             `sum(node_memory_MemTotal_bytes{job="node-exporter"{{.Selector}}} - node_memory_MemAvailable_bytes{job="node-exporter"{{.Selector}}}) by (instance)`,
+			QueryNodeNetwork:            networkQuery(nodeExporterNetworkCounters, `job="node-exporter",`+nodeExporterDevices, "instance"),
+			QueryVolumeStats:            volumeStatsQuery(`job="kubelet",persistentvolumeclaim!=""`),
+		},
+		LabelRenames: map[string]string{"instance": LabelNode},
+	},
+}
+
+// PresetNames returns the names of the shipped presets, sorted
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// QueryConfig customizes the queries run by the collectors
+type QueryConfig struct {
+	// Preset is the query set the templates start from. The default preset
+	// is used when empty.
+	Preset string
+
+	// Templates override queries of the preset by name
+	Templates map[string]string
+
+	// LabelRenames maps result labels to the labels the collectors read.
+	// They replace the renames of the preset from the same result label or
+	// onto the same label. Two renames onto the same label are rejected.
+	LabelRenames map[string]string
+
+	// Window is the range of rate(), DefaultQueryWindow when empty
+	Window string
+
+	// Selector holds label matchers added to every query, such as
+	// cluster="prod"
+	Selector string
+}
+
+// querySet is a compiled QueryConfig
+type querySet struct {
+	templates map[string]*template.Template
+	data      templateData
+
+	// sources maps the labels the collectors read to the result labels
+	// renamed onto them
+	sources map[string]string
+}
+
+// templateData holds the variables available to query templates
+type templateData struct {
+	Window   string
+	Interval string
+	Selector string
+}
+
+// newQuerySet compiles the templates of the config
+func newQuerySet(config QueryConfig) (*querySet, error) {
+	presetName := config.Preset
+	if presetName == "" {
+		presetName = PresetDefault
+	}
+	preset, ok := Presets[presetName]
+	if !ok {
+		return nil, fmt.Errorf("unknown query preset %q, known presets are %s",
+			presetName, strings.Join(PresetNames(), ", "))
+	}
+
+	sources := make(map[string]string, len(preset.Templates))
+	for name, text := range preset.Templates {
+		sources[name] = text
+	}
+	for name, text := range config.Templates {
+		if !isQueryName(name) {
+			return nil, fmt.Errorf("unknown query %q", name)
+		}
+		sources[name] = text
+	}
+
+	qs := &querySet{
+		templates: make(map[string]*template.Template, len(sources)),
+		sources:   make(map[string]string),
+		data: templateData{
+			Window: config.Window,
+		},
+	}
+	if qs.data.Window == "" {
+		qs.data.Window = DefaultQueryWindow
+	}
+	if selector := strings.TrimSpace(config.Selector); selector != "" {
+		qs.data.Selector = "," + strings.TrimPrefix(selector, ",")
+	}
+
+	for name, text := range sources {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for query %s: %w", name, err)
+		}
+		qs.templates[name] = tmpl
+		// Render once so that references to unknown variables fail early
+		if _, err := qs.render(name, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	for from, to := range preset.LabelRenames {
+		if _, overridden := config.LabelRenames[from]; !overridden {
+			qs.sources[to] = from
+		}
+	}
+	// Visit the renames in order so the error names the same pair every time
+	renamed := make([]string, 0, len(config.LabelRenames))
+	for from := range config.LabelRenames {
+		renamed = append(renamed, from)
+	}
+	sort.Strings(renamed)
+	configured := make(map[string]string, len(renamed))
+	for _, from := range renamed {
+		to := config.LabelRenames[from]
+		if other, ok := configured[to]; ok {
+			return nil, fmt.Errorf("label renames %q and %q both map to %q", other, from, to)
+		}
+		configured[to] = from
+		qs.sources[to] = from
+	}
+
+	return qs, nil
+}
+
+// isQueryName reports whether name is one of queryNames
+func isQueryName(name string) bool {
+	for _, n := range queryNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// has reports whether the set has a template for the named query
+func (qs *querySet) has(name string) bool {
+	_, ok := qs.templates[name]
+	return ok
+}
+
+// render returns the PromQL of the named query for a collection interval.
+// The interval is rounded down to seconds, and {{.Interval}} falls back to
+// the window when it is shorter than a second.
+func (qs *querySet) render(name string, interval time.Duration) (string, error) {
+	tmpl, ok := qs.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown query %q", name)
+	}
+	data := qs.data
+	data.Interval = data.Window
+	if interval = interval.Truncate(time.Second); interval > 0 {
+		data.Interval = model.Duration(interval).String()
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render query %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// label returns the value of a label the collectors read, honoring the
+// label renames
+func (qs *querySet) label(metric model.Metric, name string) string {
+	if from, ok := qs.sources[name]; ok {
+		if value, ok := metric[model.LabelName(from)]; ok {
+			return string(value)
+		}
+	}
+	return string(metric[model.LabelName(name)])
+}
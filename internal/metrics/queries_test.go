@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresets(t *testing.T) {
+	for _, name := range PresetNames() {
+		t.Run(name, func(t *testing.T) {
+			qs, err := newQuerySet(QueryConfig{Preset: name, Selector: `cluster="prod"`})
+			assert.NoError(t, err)
+			for _, query := range []string{QueryContainerCPU, QueryContainerMemory, QueryNodeCPU, QueryNodeMemory} {
+				assert.True(t, qs.has(query))
+			}
+			for _, query := range queryNames {
+				if !qs.has(query) {
+					continue
+				}
+				promQL, err := qs.render(query, 0)
+				assert.NoError(t, err)
+				assert.Contains(t, promQL, `,cluster="prod"}`)
+				assert.NotContains(t, promQL, "{{")
+			}
+		})
+	}
+}
+
+func TestNewQuerySet(t *testing.T) {
+	qs, err := newQuerySet(QueryConfig{
+		Templates: map[string]string{
+			QueryNodeCPU: `sum(rate(node_cpu_seconds_total{mode="user"{{.Selector}}}[{{.Window}}])) by (host)`,
+		},
+		LabelRenames: map[string]string{"host": LabelNode},
+		Window:       "2m",
+	})
+	assert.NoError(t, err)
+
+	promQL, err := qs.render(QueryNodeCPU, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, `sum(rate(node_cpu_seconds_total{mode="user"}[2m])) by (host)`, promQL)
+
+	// Queries that are not overridden come from the default preset
+	promQL, err = qs.render(QueryContainerCPU, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, promQL, "container_cpu_usage_seconds_total")
+	assert.Contains(t, promQL, "[2m]")
+
+	// Counter increases cover the collection interval, not the window
+	promQL, err = qs.render(QueryContainerCPUThrottling, 90*time.Second)
+	assert.NoError(t, err)
+	assert.Contains(t, promQL, "[1m30s]")
+	promQL, err = qs.render(QueryContainerPageFaults, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, promQL, "[2m]", "without an interval the window is used")
+
+	assert.Equal(t, "node-1", qs.label(model.Metric{"host": "node-1"}, LabelNode))
+	assert.Equal(t, "default", qs.label(model.Metric{"namespace": "default"}, LabelNamespace))
+
+	// A rename onto the same label replaces the one of the preset
+	qs, err = newQuerySet(QueryConfig{
+		Preset:       PresetKubePrometheusStack,
+		LabelRenames: map[string]string{"hostname": LabelNode},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "node-1", qs.label(model.Metric{"instance": "10.0.0.1:9100", "hostname": "node-1"}, LabelNode))
+}
+
+func TestNetworkQuery(t *testing.T) {
+	qs, err := newQuerySet(QueryConfig{Selector: `cluster="prod"`, Window: "2m"})
+	assert.NoError(t, err)
+
+	promQL, err := qs.render(QueryNodeNetwork, 0)
+	assert.NoError(t, err)
+	for _, counter := range nodeExporterNetworkCounters {
+		assert.Contains(t, promQL, fmt.Sprintf(`label_replace(rate(%s{%s,cluster="prod"}[2m]), "traffic", "%s", "", "")`,
+			counter.metric, nodeExporterDevices, counter.traffic))
+	}
+	assert.True(t, strings.HasSuffix(promQL, " by (node, traffic)"))
+}
+
+func TestNewQuerySet_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config QueryConfig
+	}{
+		{"unknown preset", QueryConfig{Preset: "thanos"}},
+		{"unknown query", QueryConfig{Templates: map[string]string{"diskIO": "up"}}},
+		{"invalid template", QueryConfig{Templates: map[string]string{QueryNodeCPU: "{{.Window"}}},
+		{"unknown variable", QueryConfig{Templates: map[string]string{QueryNodeCPU: "{{.Namespace}}"}}},
+		{"conflicting renames", QueryConfig{LabelRenames: map[string]string{"host": LabelNode, "instance": LabelNode}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newQuerySet(tt.config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetClusterContainerMetrics_OptionalQueries(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.FormValue("query"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"namespace_name":"default","pod_name":"web","container_name":"app"},"value":[0,"1"]}]}}`)
+	}))
+	defer server.Close()
+
+	// The GKE system metrics have no throttling, RSS or page fault series
+	client, err := NewPrometheusClientWithConfig(ClientConfig{
+		URL:     server.URL,
+		Queries: QueryConfig{Preset: PresetGKEManaged},
+	})
+	assert.NoError(t, err)
+
+	pods, err := client.GetClusterContainerMetrics(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, queries, 3, "CPU, memory and network")
+	assert.Equal(t, 1.0, pods[PodKey{Namespace: "default", Name: "web"}].CPU["app"])
+	assert.Empty(t, pods[PodKey{Namespace: "default", Name: "web"}].RSS)
+
+	// A template adds a query the preset leaves out
+	_, err = newQuerySet(QueryConfig{
+		Preset:    PresetGKEManaged,
+		Templates: map[string]string{QueryContainerMemoryRSS: `sum(container_memory_rss) by (namespace, pod, container)`},
+	})
+	assert.NoError(t, err)
+}
+
+func TestGetClusterNodeMetrics_LabelRenames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"instance":"node-1"},"value":[0,"1.5"]}]}}`)
+	}))
+	defer server.Close()
+
+	client, err := NewPrometheusClientWithConfig(ClientConfig{
+		URL:     server.URL,
+		Queries: QueryConfig{Preset: PresetKubePrometheusStack},
+	})
+	assert.NoError(t, err)
+
+	nodes, err := client.GetClusterNodeMetrics(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Contains(t, nodes, "node-1")
+	assert.Equal(t, 1.5, nodes["node-1"].CPUUsage)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import "time"
+
+// QueryStats accounts for the queries sent to Prometheus since the client
+// was created
+type QueryStats struct {
+	// Queries counts the completed queries, including failed ones
+	Queries int64
+
+	// Errors counts the failed queries, including timeouts
+	Errors int64
+
+	// Timeouts counts the queries that exceeded the query timeout
+	Timeouts int64
+
+	// InFlight is the number of queries currently running
+	InFlight int
+
+	// TotalDuration and MaxDuration summarize the query latencies
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+
+	// LastDuration is the latency of the most recent query
+	LastDuration time.Duration
+}
+
+// AverageDuration returns the mean query latency
+func (s QueryStats) AverageDuration() time.Duration {
+	if s.Queries == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Queries)
+}
+
+// Stats returns the query statistics of the client
+func (c *PrometheusClient) Stats() QueryStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// record accounts for a completed query
+func (c *PrometheusClient) record(duration time.Duration, err error, timedOut bool) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	c.stats.InFlight--
+	c.stats.Queries++
+	c.stats.TotalDuration += duration
+	c.stats.LastDuration = duration
+	if duration > c.stats.MaxDuration {
+		c.stats.MaxDuration = duration
+	}
+	if err != nil {
+		c.stats.Errors++
+	}
+	if timedOut {
+		c.stats.Timeouts++
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// AuthConfig contains the credentials and TLS settings used to reach
+// Prometheus. Certificates and keys are PEM encoded.
+type AuthConfig struct {
+	// Username and Password enable basic authentication
+	Username string
+	Password string
+
+	// BearerToken is sent in the Authorization header
+	BearerToken string
+
+	// CA verifies the server certificate instead of the system roots
+	CA []byte
+
+	// Cert and Key are the client certificate presented for mutual TLS
+	Cert []byte
+	Key  []byte
+
+	// InsecureSkipVerify disables server certificate verification
+	InsecureSkipVerify bool
+}
+
+// NewRoundTripper builds the transport used to query Prometheus with the
+// given credentials and TLS settings
+func NewRoundTripper(auth AuthConfig) (http.RoundTripper, error) {
+	basicAuth := auth.Username != "" || auth.Password != ""
+	if basicAuth && auth.BearerToken != "" {
+		return nil, fmt.Errorf("basic auth and bearer token are mutually exclusive")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: auth.InsecureSkipVerify,
+	}
+
+	if len(auth.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(auth.CA) {
+			return nil, fmt.Errorf("failed to parse CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(auth.Cert) > 0 || len(auth.Key) > 0 {
+		if len(auth.Cert) == 0 || len(auth.Key) == 0 {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.X509KeyPair(auth.Cert, auth.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	switch {
+	case basicAuth:
+		return &authRoundTripper{
+			next: transport,
+			authorize: func(req *http.Request) {
+				req.SetBasicAuth(auth.Username, auth.Password)
+			},
+		}, nil
+	case auth.BearerToken != "":
+		return &authRoundTripper{
+			next: transport,
+			authorize: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+			},
+		}, nil
+	default:
+		return transport, nil
+	}
+}
+
+// authRoundTripper adds credentials to every request
+type authRoundTripper struct {
+	next      http.RoundTripper
+	authorize func(req *http.Request)
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	rt.authorize(req)
+	return rt.next.RoundTrip(req)
+}
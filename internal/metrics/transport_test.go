@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRoundTripper_Auth(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		auth AuthConfig
+		want string
+	}{
+		{"none", AuthConfig{}, ""},
+		{"basic auth", AuthConfig{Username: "admin", Password: "secret"}, "Basic YWRtaW46c2VjcmV0"},
+		{"bearer token", AuthConfig{BearerToken: "token"}, "Bearer token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := NewRoundTripper(tt.auth)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			assert.NoError(t, err)
+			resp, err := rt.RoundTrip(req)
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.want, got.Header.Get("Authorization"))
+			// The caller's request is left untouched
+			assert.Empty(t, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestNewRoundTripper_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	get := func(auth AuthConfig) error {
+		rt, err := NewRoundTripper(auth)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// The test server's certificate is not trusted by default
+	assert.Error(t, get(AuthConfig{}))
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, get(AuthConfig{CA: ca}))
+	assert.NoError(t, get(AuthConfig{InsecureSkipVerify: true}))
+}
+
+func TestNewRoundTripper_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		auth AuthConfig
+	}{
+		{"basic auth and bearer token", AuthConfig{Username: "admin", BearerToken: "token"}},
+		{"invalid CA", AuthConfig{CA: []byte("not a certificate")}},
+		{"certificate without key", AuthConfig{Cert: []byte("cert")}},
+		{"invalid key pair", AuthConfig{Cert: []byte("cert"), Key: []byte("key")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRoundTripper(tt.auth)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// minRangeStep is the smallest resolution of the range queries, about
+	// the scrape interval of most Prometheus setups
+	minRangeStep = 15 * time.Second
+
+	// maxRangePoints bounds the samples returned per series by a range query
+	maxRangePoints = 100
+)
+
+// UsageStats summarizes the samples of a series over a window
+type UsageStats struct {
+	Avg float64
+	P50 float64
+	P95 float64
+	Max float64
+}
+
+// newUsageStats computes the statistics of values. The percentiles are
+// interpolated linearly between samples like quantile_over_time.
+func newUsageStats(values []float64) UsageStats {
+	sorted := make([]float64, 0, len(values))
+	var sum float64
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sorted = append(sorted, v)
+		sum += v
+	}
+	if len(sorted) == 0 {
+		return UsageStats{}
+	}
+	sort.Float64s(sorted)
+
+	return UsageStats{
+		Avg: sum / float64(len(sorted)),
+		P50: quantile(sorted, 0.5),
+		P95: quantile(sorted, 0.95),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// quantile returns the q-quantile of sorted, which must not be empty
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	weight := rank - float64(lower)
+	return sorted[lower]*(1-weight) + sorted[lower+1]*weight
+}
+
+// rangeStep returns the resolution of a range query over window
+func rangeStep(window time.Duration) time.Duration {
+	step := window / maxRangePoints
+	if step < minRangeStep {
+		step = minRangeStep
+	}
+	return step
+}
+
+// series is a query result reduced to its latest value and, for range
+// queries, the statistics of all its samples
+type series struct {
+	metric model.Metric
+	value  float64
+	stats  UsageStats
+}
+
+// seriesOf converts an instant or range query result
+func seriesOf(value model.Value) []series {
+	var result []series
+	switch v := value.(type) {
+	case model.Vector:
+		for _, sample := range v {
+			result = append(result, series{metric: sample.Metric, value: float64(sample.Value)})
+		}
+	case model.Matrix:
+		for _, stream := range v {
+			if len(stream.Values) == 0 {
+				continue
+			}
+			values := make([]float64, len(stream.Values))
+			for i, pair := range stream.Values {
+				values[i] = float64(pair.Value)
+			}
+			result = append(result, series{
+				metric: stream.Metric,
+				value:  values[len(values)-1],
+				stats:  newUsageStats(values),
+			})
+		}
+	}
+	return result
+}
+
+// rate returns the value of a series of rates over window: the average of
+// its samples for range queries, its only sample otherwise
+func (s series) rate(window time.Duration) float64 {
+	if window > 0 {
+		return s.stats.Avg
+	}
+	return s.value
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUsageStats(t *testing.T) {
+	stats := newUsageStats([]float64{3, 1, 2, 10, 4, math.NaN()})
+	assert.InDelta(t, 4.0, stats.Avg, 1e-9)
+	assert.InDelta(t, 3.0, stats.P50, 1e-9)
+	assert.InDelta(t, 8.8, stats.P95, 1e-9)
+	assert.Equal(t, 10.0, stats.Max)
+
+	assert.Equal(t, UsageStats{Avg: 5, P50: 5, P95: 5, Max: 5}, newUsageStats([]float64{5}))
+	assert.Equal(t, UsageStats{}, newUsageStats(nil))
+}
+
+func TestRangeStep(t *testing.T) {
+	assert.Equal(t, minRangeStep, rangeStep(time.Minute))
+	assert.Equal(t, 36*time.Second, rangeStep(time.Hour))
+}
@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSkipped is returned by a job's Run function when it decided not to do
+// any work. The job's last run status is left unchanged.
+var ErrSkipped = errors.New("run skipped")
+
+// Job is a unit of work run on a fixed interval
+type Job struct {
+	// Name identifies the job. Scheduling a job with the name of a running
+	// job replaces it.
+	Name string
+
+	// Interval is the time between the starts of two runs
+	Interval time.Duration
+
+	// Run performs the work
+	Run func(ctx context.Context) error
+}
+
+// JobStatus describes the state of a scheduled job
+type JobStatus struct {
+	Name     string
+	Interval time.Duration
+
+	// Running is true while a run is in progress
+	Running bool
+
+	// LastRun is when the last completed run started
+	LastRun time.Time
+
+	// LastDuration is how long the last completed run took
+	LastDuration time.Duration
+
+	// LastError is the error returned by the last completed run
+	LastError error
+
+	// NextRun is when the next run is due
+	NextRun time.Time
+}
+
+// Scheduler runs each job on its own ticker. Runs of the same job never
+// overlap: when a run takes longer than the interval the next run starts as
+// soon as it finishes.
+type Scheduler struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	entries map[string]*entry
+	wg      sync.WaitGroup
+}
+
+type entry struct {
+	job    Job
+	cancel context.CancelFunc
+
+	// done is closed when the job's goroutine exits. prev is the done
+	// channel of the entry this one replaced; the first run waits for it so
+	// that a rescheduled job never overlaps with its previous incarnation.
+	done chan struct{}
+	prev chan struct{}
+
+	// status is guarded by Scheduler.mu
+	status JobStatus
+}
+
+// New creates a scheduler. Jobs start running once Start is called.
+func New() *Scheduler {
+	return &Scheduler{
+		entries: make(map[string]*entry),
+	}
+}
+
+// Start runs the scheduled jobs until ctx is cancelled and then waits for
+// in-flight runs to finish. It implements manager.Runnable.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, e := range s.entries {
+		s.startLocked(e)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	s.wg.Wait()
+	return nil
+}
+
+// Schedule replaces the set of scheduled jobs. Jobs whose interval did not
+// change keep their timing and pick up the new Run function on their next
+// run. Jobs with a new interval are restarted and jobs missing from the set
+// are stopped.
+func (s *Scheduler) Schedule(jobs ...Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		wanted[job.Name] = true
+
+		var prev chan struct{}
+		if e, ok := s.entries[job.Name]; ok {
+			if e.job.Interval == job.Interval {
+				e.job.Run = job.Run
+				continue
+			}
+			if e.cancel != nil {
+				s.stopLocked(e)
+				prev = e.done
+			}
+		}
+
+		e := &entry{
+			job:    job,
+			done:   make(chan struct{}),
+			prev:   prev,
+			status: JobStatus{Name: job.Name, Interval: job.Interval},
+		}
+		s.entries[job.Name] = e
+		if s.ctx != nil {
+			s.startLocked(e)
+		}
+	}
+
+	for name, e := range s.entries {
+		if !wanted[name] {
+			s.stopLocked(e)
+			delete(s.entries, name)
+		}
+	}
+}
+
+// Status returns the status of all scheduled jobs ordered by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		statuses = append(statuses, e.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// startLocked launches the goroutine of a job. s.mu must be held.
+func (s *Scheduler) startLocked(e *entry) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	e.cancel = cancel
+	e.status.NextRun = time.Now()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(e.done)
+		s.loop(ctx, e)
+	}()
+}
+
+// stopLocked cancels the goroutine of a job. s.mu must be held.
+func (s *Scheduler) stopLocked(e *entry) {
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+// loop runs a job immediately and then once per interval
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	if e.prev != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.prev:
+		}
+	}
+
+	ticker := time.NewTicker(e.job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(ctx, e)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	s.mu.Lock()
+	run := e.job.Run
+	e.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := run(ctx)
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	e.status.Running = false
+	if !errors.Is(err, ErrSkipped) {
+		e.status.LastRun = start
+		e.status.LastDuration = duration
+		e.status.LastError = err
+	}
+	e.status.NextRun = start.Add(e.job.Interval)
+	if now := time.Now(); e.status.NextRun.Before(now) {
+		// The run overran its interval, the next one starts right away
+		e.status.NextRun = now
+	}
+	s.mu.Unlock()
+}
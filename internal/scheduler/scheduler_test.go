@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startScheduler(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestScheduler_RunsJobsOnTheirOwnIntervals(t *testing.T) {
+	var fast, slow atomic.Int32
+	s := New()
+	s.Schedule(
+		Job{Name: "fast", Interval: 10 * time.Millisecond, Run: func(context.Context) error { fast.Add(1); return nil }},
+		Job{Name: "slow", Interval: time.Hour, Run: func(context.Context) error { slow.Add(1); return nil }},
+	)
+	startScheduler(t, s)
+
+	assert.Eventually(t, func() bool { return fast.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), slow.Load(), "jobs run once immediately and then per interval")
+}
+
+func TestScheduler_NoOverlappingRuns(t *testing.T) {
+	var running, maxRunning, runs atomic.Int32
+	s := New()
+	s.Schedule(Job{Name: "slow", Interval: time.Millisecond, Run: func(context.Context) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		runs.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}})
+	startScheduler(t, s)
+
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), maxRunning.Load())
+}
+
+func TestScheduler_Status(t *testing.T) {
+	s := New()
+	s.Schedule(Job{Name: "failing", Interval: time.Hour, Run: func(context.Context) error { return errors.New("boom") }})
+	startScheduler(t, s)
+
+	assert.Eventually(t, func() bool { return !s.Status()[0].LastRun.IsZero() }, time.Second, 5*time.Millisecond)
+
+	status := s.Status()[0]
+	assert.Equal(t, "failing", status.Name)
+	assert.EqualError(t, status.LastError, "boom")
+	assert.WithinDuration(t, status.LastRun.Add(time.Hour), status.NextRun, time.Millisecond)
+}
+
+func TestScheduler_Reschedule(t *testing.T) {
+	var oldRuns, newRuns atomic.Int32
+	s := New()
+	s.Schedule(
+		Job{Name: "kept", Interval: time.Hour, Run: func(context.Context) error { oldRuns.Add(1); return nil }},
+		Job{Name: "removed", Interval: time.Hour, Run: func(context.Context) error { return nil }},
+	)
+	startScheduler(t, s)
+	assert.Eventually(t, func() bool { return oldRuns.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Same interval: the job keeps its timing and is not run again right away
+	s.Schedule(Job{Name: "kept", Interval: time.Hour, Run: func(context.Context) error { newRuns.Add(1); return nil }})
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), newRuns.Load())
+	assert.Len(t, s.Status(), 1)
+
+	// New interval: the job is restarted
+	s.Schedule(Job{Name: "kept", Interval: 30 * time.Minute, Run: func(context.Context) error { newRuns.Add(1); return nil }})
+	assert.Eventually(t, func() bool { return newRuns.Load() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), oldRuns.Load())
+}
+
+func TestScheduler_SkippedRunsKeepLastStatus(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	s.Schedule(Job{Name: "flaky", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("boom")
+		}
+		return ErrSkipped
+	}})
+	startScheduler(t, s)
+
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+	assert.EqualError(t, s.Status()[0].LastError, "boom")
+}
@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/cluster"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+)
+
+// HakonGoSink sends metrics to the HakonGo API
+type HakonGoSink struct {
+	name   string
+	client *api.Client
+	spool  *api.Spool
+}
+
+// NewHakonGoSink creates a sink for the HakonGo API. When spool is not nil
+// every batch is persisted before it is sent.
+func NewHakonGoSink(name string, client *api.Client, spool *api.Spool) *HakonGoSink {
+	return &HakonGoSink{
+		name:   name,
+		client: client,
+		spool:  spool,
+	}
+}
+
+// Name returns the sink's name
+func (s *HakonGoSink) Name() string {
+	return s.name
+}
+
+// Write sends events to the events endpoint and all other resources to the
+// metrics endpoint, split into size-bounded chunks
+func (s *HakonGoSink) Write(ctx context.Context, batch *Batch) error {
+	var eventMetrics []collector.ResourceMetrics
+	var regularMetrics []collector.ResourceMetrics
+	for _, metric := range batch.Metrics {
+		if metric.Kind == "Event" {
+			eventMetrics = append(eventMetrics, metric)
+		} else {
+			regularMetrics = append(regularMetrics, metric)
+		}
+	}
+
+	var batches []*api.Batch
+	if len(regularMetrics) > 0 {
+		chunks, err := s.client.NewMetricsBatches(batch.ClusterID, batch.CycleID, regularMetrics)
+		if err != nil {
+			return err
+		}
+		batches = append(batches, chunks...)
+	}
+	if len(eventMetrics) > 0 {
+		chunks, err := s.client.NewEventMetricsBatches(batch.ClusterID, batch.CycleID, contextMap(batch.Context), eventMetrics)
+		if err != nil {
+			return err
+		}
+		batches = append(batches, chunks...)
+	}
+	if len(batches) == 0 {
+		return nil
+	}
+
+	if s.spool != nil {
+		return s.spool.Send(ctx, batches...)
+	}
+	return s.client.SendBatches(ctx, batches)
+}
+
+// contextMap builds the cluster context sent with event metrics
+func contextMap(clusterCtx *cluster.ClusterContext) map[string]interface{} {
+	if clusterCtx == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"name":     clusterCtx.Name,
+		"provider": clusterCtx.Provider.Name,
+		"region":   clusterCtx.Provider.Region,
+		"zone":     clusterCtx.Provider.Zone,
+		"labels":   clusterCtx.Labels,
+	}
+}
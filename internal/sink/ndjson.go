@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WriterSink writes one JSON record per line to an io.Writer
+type WriterSink struct {
+	name string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink that writes NDJSON records to w
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+// NewStdoutSink creates a sink that writes NDJSON records to standard output
+func NewStdoutSink(name string) *WriterSink {
+	return NewWriterSink(name, os.Stdout)
+}
+
+// Name returns the sink's name
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+// Write encodes every resource in the batch as a separate line
+func (s *WriterSink) Write(_ context.Context, batch *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writeNDJSON(s.w, batch)
+}
+
+// FileSink appends NDJSON records to a local file
+type FileSink struct {
+	name string
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileSink creates a sink that appends NDJSON records to path. The file
+// is reopened on every write so that external log rotation is picked up.
+func NewFileSink(name, path string) *FileSink {
+	return &FileSink{name: name, path: path}
+}
+
+// Name returns the sink's name
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+// Write appends every resource in the batch as a separate line
+func (s *FileSink) Write(_ context.Context, batch *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", s.path, err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.path, err)
+	}
+
+	if err := writeNDJSON(f, batch); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func writeNDJSON(w io.Writer, batch *Batch) error {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for _, record := range records(batch) {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hakongo/kubernetes-connector/internal/cluster"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+)
+
+// Sink types accepted in the ConnectorConfig spec
+const (
+	TypeHakonGo = "hakongo"
+	TypeFile    = "file"
+	TypeStdout  = "stdout"
+	TypeWebhook = "webhook"
+)
+
+// Batch is the output of one collection cycle handed to a sink
+type Batch struct {
+	// ClusterID identifies the cluster the metrics were collected from
+	ClusterID string
+
+	// CycleID identifies the collection cycle
+	CycleID string
+
+	// Context describes the cluster
+	Context *cluster.ClusterContext
+
+	// Metrics are the collected resource metrics
+	Metrics []collector.ResourceMetrics
+}
+
+// Sink is a destination for collected metrics
+type Sink interface {
+	// Name returns the sink's name
+	Name() string
+
+	// Write delivers a batch to the destination
+	Write(ctx context.Context, batch *Batch) error
+}
+
+// Record is the line format written by the NDJSON, stdout and webhook sinks:
+// a resource metric annotated with the cluster and cycle it belongs to
+type Record struct {
+	ClusterID string `json:"cluster_id"`
+	CycleID   string `json:"cycle_id"`
+	collector.ResourceMetrics
+}
+
+func records(batch *Batch) []Record {
+	out := make([]Record, 0, len(batch.Metrics))
+	for _, m := range batch.Metrics {
+		out = append(out, Record{
+			ClusterID:       batch.ClusterID,
+			CycleID:         batch.CycleID,
+			ResourceMetrics: m,
+		})
+	}
+	return out
+}
+
+// Multi fans a batch out to several sinks
+type Multi struct {
+	sinks []Sink
+}
+
+// NewMulti creates a sink that writes to all of the given sinks
+func NewMulti(sinks ...Sink) *Multi {
+	return &Multi{sinks: sinks}
+}
+
+// Name returns the names of the wrapped sinks
+func (m *Multi) Name() string {
+	names := make([]string, 0, len(m.sinks))
+	for _, s := range m.sinks {
+		names = append(names, s.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Write writes the batch to every sink. A failing sink does not prevent the
+// others from receiving the batch; all failures are returned joined.
+func (m *Multi) Write(ctx context.Context, batch *Batch) error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Write(ctx, batch); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KindFilter passes only resources of the given kinds to the wrapped sink
+type KindFilter struct {
+	sink  Sink
+	kinds map[string]bool
+}
+
+// NewKindFilter wraps s so that it only receives resources of the given
+// kinds. An empty list of kinds returns s unchanged.
+func NewKindFilter(s Sink, kinds []string) Sink {
+	if len(kinds) == 0 {
+		return s
+	}
+
+	f := &KindFilter{sink: s, kinds: make(map[string]bool, len(kinds))}
+	for _, k := range kinds {
+		f.kinds[k] = true
+	}
+	return f
+}
+
+// Name returns the name of the wrapped sink
+func (f *KindFilter) Name() string {
+	return f.sink.Name()
+}
+
+// Write forwards the matching resources. Nothing is written if no resource matches.
+func (f *KindFilter) Write(ctx context.Context, batch *Batch) error {
+	filtered := make([]collector.ResourceMetrics, 0, len(batch.Metrics))
+	for _, m := range batch.Metrics {
+		if f.kinds[m.Kind] {
+			filtered = append(filtered, m)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+
+	out := *batch
+	out.Metrics = filtered
+	return f.sink.Write(ctx, &out)
+}
@@ -0,0 +1,159 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hakongo/kubernetes-connector/internal/api"
+	"github.com/hakongo/kubernetes-connector/internal/cluster"
+	"github.com/hakongo/kubernetes-connector/internal/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	name    string
+	err     error
+	batches []*Batch
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Write(_ context.Context, batch *Batch) error {
+	s.batches = append(s.batches, batch)
+	return s.err
+}
+
+func testBatch() *Batch {
+	return &Batch{
+		ClusterID: "test-cluster",
+		CycleID:   "cycle-1",
+		Context:   &cluster.ClusterContext{Name: "test-cluster"},
+		Metrics: []collector.ResourceMetrics{
+			{Name: "pod-a", Namespace: "default", Kind: "Pod"},
+			{Name: "node-a", Kind: "Node"},
+			{Name: "event-a", Namespace: "default", Kind: "Event"},
+		},
+	}
+}
+
+func TestMulti_WritesToAllSinksDespiteFailures(t *testing.T) {
+	failing := &recordingSink{name: "failing", err: errors.New("boom")}
+	healthy := &recordingSink{name: "healthy"}
+
+	multi := NewMulti(failing, healthy)
+	err := multi.Write(context.Background(), testBatch())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sink failing: boom")
+	assert.Len(t, failing.batches, 1)
+	assert.Len(t, healthy.batches, 1)
+	assert.Equal(t, "failing,healthy", multi.Name())
+}
+
+func TestKindFilter(t *testing.T) {
+	inner := &recordingSink{name: "lake"}
+
+	s := NewKindFilter(inner, []string{"Pod", "Node"})
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+	assert.Len(t, inner.batches, 1)
+	assert.Len(t, inner.batches[0].Metrics, 2)
+	for _, m := range inner.batches[0].Metrics {
+		assert.NotEqual(t, "Event", m.Kind)
+	}
+
+	// Nothing is written when no resource matches
+	s = NewKindFilter(inner, []string{"Ingress"})
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+	assert.Len(t, inner.batches, 1)
+
+	// No kinds means no filtering
+	assert.Same(t, inner, NewKindFilter(inner, nil))
+}
+
+func TestWriterSink_WritesNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewWriterSink("stdout", &buf)
+
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+
+	var records []Record
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 3)
+	assert.Equal(t, "test-cluster", records[0].ClusterID)
+	assert.Equal(t, "cycle-1", records[0].CycleID)
+	assert.Equal(t, "pod-a", records[0].Name)
+}
+
+func TestFileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lake", "metrics.ndjson")
+	s := NewFileSink("file", path)
+
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, bytes.Count(data, []byte("\n")))
+}
+
+func TestWebhookSink(t *testing.T) {
+	var payload WebhookPayload
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink("webhook", WebhookConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, "cycle-1", payload.CycleID)
+	assert.Len(t, payload.Resources, 3)
+}
+
+func TestWebhookSink_Non2xx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink("webhook", WebhookConfig{URL: server.URL})
+	assert.Error(t, s.Write(context.Background(), testBatch()))
+}
+
+func TestHakonGoSink_SplitsEvents(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := api.NewClient(api.ClientConfig{BaseURL: server.URL, APIKey: "test"})
+	s := NewHakonGoSink(TypeHakonGo, client, nil)
+
+	assert.NoError(t, s.Write(context.Background(), testBatch()))
+	assert.Equal(t, map[string]int{"/v1/metrics": 1, "/v1/metrics/events": 1}, paths)
+}
@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hakongo/kubernetes-connector/internal/cluster"
+)
+
+// WebhookPayload is the body posted by the webhook sink
+type WebhookPayload struct {
+	ClusterID string                  `json:"cluster_id"`
+	CycleID   string                  `json:"cycle_id"`
+	Context   *cluster.ClusterContext `json:"context,omitempty"`
+	Resources []Record                `json:"resources"`
+}
+
+// WebhookConfig contains configuration for the webhook sink
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+// WebhookSink posts each batch as a JSON document to an HTTP endpoint
+type WebhookSink struct {
+	name       string
+	config     WebhookConfig
+	httpClient *http.Client
+}
+
+// NewWebhookSink creates a sink that posts batches to config.URL
+func NewWebhookSink(name string, config WebhookConfig) *WebhookSink {
+	return &WebhookSink{
+		name:   name,
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+	}
+}
+
+// Name returns the sink's name
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+// Write posts the batch and expects a 2xx response
+func (s *WebhookSink) Write(ctx context.Context, batch *Batch) error {
+	payload, err := json.Marshal(WebhookPayload{
+		ClusterID: batch.ClusterID,
+		CycleID:   batch.CycleID,
+		Context:   batch.Context,
+		Resources: records(batch),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metricsv1beta1fake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
 )
@@ -73,8 +74,18 @@ func TestPodCollector(t *testing.T) {
 	}
 
 	kubeClient := fake.NewSimpleClientset(podObjects...)
-	// Create metrics client - not used in the updated code
-	_ = metricsv1beta1fake.NewSimpleClientset(podMetricsObjects...)
+	cache := collector.NewCache(kubeClient, nil)
+	defer cache.Stop()
+	// The fake metrics clientset tracks PodMetrics under a different resource
+	// than the one it lists, so the list is served by a reactor
+	metricsClient := metricsv1beta1fake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		list := &metricsv1beta1.PodMetricsList{}
+		for _, obj := range podMetricsObjects {
+			list.Items = append(list.Items, *obj.(*metricsv1beta1.PodMetrics))
+		}
+		return true, list, nil
+	})
 
 	// Create the collector
 	config := collector.CollectorConfig{
@@ -85,8 +96,8 @@ func TestPodCollector(t *testing.T) {
 		ResourceTypes: []string{"Pod"},
 		MaxConcurrentCollections: 10,
 	}
-	// Create a nil prometheus client for now
-	podCollector := collector.NewPodCollector(kubeClient, nil, config, false)
+	// Without Prometheus the usage comes from the Metrics Server
+	podCollector := collector.NewPodCollector(cache, metricsClient, nil, nil, nil, config, false, true)
 
 	// Collect metrics
 	metrics, err := podCollector.Collect(context.Background())
@@ -105,6 +116,13 @@ func TestPodCollector(t *testing.T) {
 					// For now, we'll just check that the CPU and Memory metrics exist
 			assert.NotNil(t, metric.CPU, "CPU metrics should not be nil")
 			assert.NotNil(t, metric.Memory, "Memory metrics should not be nil")
+
+			if assert.Len(t, metric.Containers, 1) {
+				container := metric.Containers[0]
+				assert.Equal(t, int64(100000000), container.CPU.UsageNanoCores, "CPU usage should come from the Metrics Server")
+				assert.Equal(t, int64(100*1024*1024), container.Memory.UsageBytes, "Memory usage should come from the Metrics Server")
+				assert.Equal(t, collector.SourceMetricsServer, container.Source)
+			}
 			
 			assert.Equal(t, map[string]string{"app": "test"}, metric.Labels, "Labels should match")
 			// Annotations are now stored in the Status field
@@ -130,6 +148,8 @@ func TestNamespaceCollector(t *testing.T) {
 	}
 
 	kubeClient := fake.NewSimpleClientset(namespaceObjects...)
+	cache := collector.NewCache(kubeClient, nil)
+	defer cache.Stop()
 
 	// Create the collector
 	config := collector.CollectorConfig{
@@ -140,7 +160,7 @@ func TestNamespaceCollector(t *testing.T) {
 		ResourceTypes: []string{"Namespace"},
 		MaxConcurrentCollections: 10,
 	}
-	namespaceCollector := collector.NewNamespaceCollector(kubeClient, config)
+	namespaceCollector := collector.NewNamespaceCollector(cache, config)
 	
 	// We'll skip the assertions that are failing for now
 
@@ -194,6 +214,8 @@ func TestServiceCollector(t *testing.T) {
 	}
 
 	kubeClient := fake.NewSimpleClientset(serviceObjects...)
+	cache := collector.NewCache(kubeClient, nil)
+	defer cache.Stop()
 
 	// Create the collector
 	config := collector.CollectorConfig{
@@ -205,7 +227,7 @@ func TestServiceCollector(t *testing.T) {
 		MaxConcurrentCollections: 10,
 	}
 	// Create a fake versioned client for service collector
-	serviceCollector := collector.NewServiceCollector(kubeClient, nil, config)
+	serviceCollector := collector.NewServiceCollector(cache, nil, config)
 	
 	// We'll skip the assertions that are failing for now
 
@@ -280,6 +302,8 @@ func TestIngressCollector(t *testing.T) {
 	}
 
 	kubeClient := fake.NewSimpleClientset(ingressObjects...)
+	cache := collector.NewCache(kubeClient, nil)
+	defer cache.Stop()
 
 	// Create the collector
 	config := collector.CollectorConfig{
@@ -290,7 +314,7 @@ func TestIngressCollector(t *testing.T) {
 		ResourceTypes: []string{"Ingress"},
 		MaxConcurrentCollections: 10,
 	}
-	ingressCollector := collector.NewIngressCollector(kubeClient, config)
+	ingressCollector := collector.NewIngressCollector(cache, config)
 	
 	// We'll skip the assertions that are failing for now
 